@@ -3,7 +3,10 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/mvazquezc/karma-bot/pkg/commands"
 	"github.com/mvazquezc/karma-bot/pkg/karmabot"
 )
 
@@ -13,6 +16,27 @@ func main() {
     apiToken := os.Getenv("API_TOKEN")
     // Check if database exists
     dbFile := "/var/tmp/karma.db"
-    // Send db connection details to NewKarmaBot
-    karmabot.NewKarmaBot(apiToken, dbFile)
+    // Workspace super-admins, comma separated list of user IDs
+    superAdmins := commands.SuperAdmins{
+        Users:         strings.FieldsFunc(os.Getenv("SUPER_ADMINS"), func(r rune) bool { return r == ',' || r == ' ' }),
+        SyncFromSlack: os.Getenv("SYNC_SLACK_ADMINS") == "true",
+    }
+    // Time Slack users, channels and channel members are cached, like 5m
+    cacheTTL, err := time.ParseDuration(os.Getenv("SLACK_CACHE_TTL"))
+    if err != nil && len(os.Getenv("SLACK_CACHE_TTL")) > 0 {
+        log.Printf("Invalid SLACK_CACHE_TTL %s, using the default: %s", os.Getenv("SLACK_CACHE_TTL"), err)
+    }
+    // Send bot configuration to NewKarmaBot, the slash command server only starts when a signing secret is configured
+    karmabot.NewKarmaBot(karmabot.Config{
+        APIToken:         apiToken,
+        DBFile:           dbFile,
+        SuperAdmins:      superAdmins,
+        SigningSecret:    os.Getenv("SLACK_SIGNING_SECRET"),
+        ListenAddress:    os.Getenv("LISTEN_ADDRESS"),
+        // Metrics are served apart from the slash command server, which is reachable by Slack
+        MetricsAddress:   os.Getenv("METRICS_ADDR"),
+        CacheTTL:         cacheTTL,
+        // Karma stored under user names by older versions is only moved to user IDs when asked to
+        MigrateUserKarma: os.Getenv("MIGRATE_USER_KARMA") == "true",
+    })
 }
@@ -1,8 +1,14 @@
 module github.com/mvazquezc/karma-bot
 
-go 1.16
+go 1.18
 
 require (
 	github.com/mattn/go-sqlite3 v1.14.9
 	github.com/slack-go/slack v0.9.5
+	golang.org/x/text v0.3.7
+)
+
+require (
+	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/pkg/errors v0.8.0 // indirect
 )
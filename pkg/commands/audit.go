@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+)
+
+// auditLogDefaultEntries number of audit entries returned when no number is requested
+const auditLogDefaultEntries = 10
+
+// auditLogMaxEntries maximum number of audit entries returned by a single command
+const auditLogMaxEntries = 50
+
+// audit persists an admin operation in the audit log
+func (cmd *Commands) audit(channel string, who string, command string, arguments string, before string, after string) {
+	entry := database.AuditEntry{
+		Channel:   channel,
+		Actor:     who,
+		Command:   command,
+		Arguments: strings.TrimSpace(arguments),
+		Before:    before,
+		After:     after,
+		Timestamp: time.Now().Unix(),
+	}
+	cmd.db.AddAuditEntry(entry)
+}
+
+// auditKarma returns the karma value stored in the audit log for a word without karma yet
+func auditKarma(karma int) string {
+	if karma == -256256 {
+		return ""
+	}
+	return strconv.Itoa(karma)
+}
+
+// usage: kb get audit [n]
+func (cmd *Commands) getAudit(channel string, parameters string, who string) Response {
+	if !cmd.hasRole(channel, who, RoleAdmin) {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		return failure(cmd.t(channel, "audit.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
+	}
+	entries := auditLogDefaultEntries
+	if len(parameters) > 0 {
+		requestedEntries, err := strconv.Atoi(parameters)
+		if err != nil || requestedEntries <= 0 {
+			log.Printf("Received incorrect number of entries %s", parameters)
+			return failure(cmd.t(channel, "audit.usage"))
+		}
+		entries = requestedEntries
+	}
+	if entries > auditLogMaxEntries {
+		entries = auditLogMaxEntries
+	}
+	log.Printf("Getting last %d audit entries for channel %s", entries, channel)
+	auditLog := cmd.db.GetAuditLog(channel, entries)
+	if len(auditLog) == 0 {
+		return Response{Text: cmd.t(channel, "audit.empty"), Visibility: VisibilityEphemeral}
+	}
+	commandResult := cmd.t(channel, "audit.title", "entries", strconv.Itoa(len(auditLog)))
+	for _, entry := range auditLog {
+		when := time.Unix(entry.Timestamp, 0).Format("2006-01-02 15:04:05")
+		commandResult += "- " + when + " <@" + strings.ToUpper(entry.Actor) + "> `" + entry.Command
+		if len(entry.Arguments) > 0 {
+			commandResult += " " + entry.Arguments
+		}
+		commandResult += "` (`" + cmd.auditValue(channel, entry.Before) + "` → `" + cmd.auditValue(channel, entry.After) + "`)\n"
+	}
+	// The audit log is only shown to the requester
+	return Response{Text: commandResult, Visibility: VisibilityEphemeral}
+}
+
+// auditValue returns the value shown for an empty audit value
+func (cmd *Commands) auditValue(channel string, value string) string {
+	if len(value) <= 0 {
+		return cmd.t(channel, "audit.none")
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/slack-go/slack"
+)
+
+// maxRankBlocks maximum rank entries rendered as blocks, Slack messages are limited to 50 blocks
+const maxRankBlocks = 45
+
+// RankBlockPrefix prefix of the block ID of rank buttons, followed by the rank command group (karma or globalkarma)
+// and the channel the rank belongs to, like rank_karma:general, ranks can be sent by DM so the channel is not
+// always the one where the buttons are clicked
+const RankBlockPrefix = "rank_"
+
+// Action IDs of the rank buttons
+const (
+	RankActionPrev   = "rank_prev"
+	RankActionNext   = "rank_next"
+	RankActionPeriod = "rank_period"
+)
+
+// rankMedals medals shown for the top 3 positions of a rank
+var rankMedals = map[int]string{1: ":first_place_medal:", 2: ":second_place_medal:", 3: ":third_place_medal:"}
+
+// useBlocks returns true if the channel renders messages using Block Kit
+func (cmd *Commands) useBlocks(channel string) bool {
+	return cmd.db.GetBoolSetting(channel, "use_block_kit")
+}
+
+// rankBlocks returns the Block Kit rendering of a rank page: a header, a row per entry with its position medal,
+// and a context with the time window and page. Ranks too long for a single message return no blocks, so the
+// plain text rendering is used instead. Entries are users when usersRank is true, and their avatars are shown
+func (cmd *Commands) rankBlocks(language string, title string, window string, rank database.RankPage, usersRank bool) []slack.Block {
+	if len(rank.Entries) > maxRankBlocks {
+		log.Printf("Rank has %d entries, too many to be rendered as blocks", len(rank.Entries))
+		return nil
+	}
+	blocks := []slack.Block{slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, title, true, false))}
+	if rank.TotalWords == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, i18n.T(language, "rank.empty"), false, false), nil, nil))
+	}
+	for _, entry := range rank.Entries {
+		medal, hasMedal := rankMedals[entry.Position]
+		if !hasMedal {
+			medal = ":small_blue_diamond:"
+		}
+		name := "`" + entry.Word + "`"
+		var accessory *slack.Accessory
+		if usersRank {
+			name = "<@" + strings.ToUpper(entry.Word) + ">"
+			avatar := cmd.userAvatar(entry.Word)
+			if len(avatar) > 0 {
+				accessory = slack.NewAccessory(slack.NewImageBlockElement(avatar, entry.Word))
+			}
+		}
+		fields := []*slack.TextBlockObject{
+			slack.NewTextBlockObject(slack.MarkdownType, medal+" *"+strconv.Itoa(entry.Position)+".* "+name, false, false),
+			slack.NewTextBlockObject(slack.MarkdownType, i18n.T(language, "rank.karma_points", "karma", strconv.Itoa(entry.Karma)), false, false),
+		}
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, accessory))
+	}
+	context := window
+	if rank.TotalPages > 1 {
+		context += " · " + i18n.T(language, "rank.page", "page", strconv.Itoa(rank.Page), "pages", strconv.Itoa(rank.TotalPages))
+	}
+	context += " · " + i18n.T(language, "rank.total", "total", strconv.Itoa(rank.TotalWords))
+	blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, context, false, false)))
+	return blocks
+}
+
+// userAvatar returns the avatar URL for a user, empty if it cannot be retrieved
+func (cmd *Commands) userAvatar(user string) string {
+	userInfo, err := cmd.api.GetUserInfo(strings.ToUpper(user))
+	if err != nil {
+		log.Printf("Cannot get user information for user %s: %s", user, err)
+		return ""
+	}
+	return userInfo.Profile.Image48
+}
+
+// rankPeriods periods the rank period button cycles through
+var rankPeriods = []string{"all", "today", "week", "month", "year"}
+
+// appendRankActions adds the Prev, Next and Period buttons to the blocks of a rank when interactivity is enabled
+// The value of each button holds the rank arguments to render, so interactions just run the rank command again
+func (cmd *Commands) appendRankActions(language string, blocks []slack.Block, channel string, group string, period string, rank database.RankPage, pageSize int, bottom bool) []slack.Block {
+	if !cmd.interactive || len(blocks) == 0 {
+		return blocks
+	}
+	// Full and bottom ranks have a single page, the period button keeps them as they are
+	mode := ""
+	if pageSize == 0 {
+		mode = " all"
+	} else if bottom {
+		mode = " bottom"
+	}
+	var buttons []slack.BlockElement
+	if len(mode) == 0 && rank.Page > 1 {
+		buttons = append(buttons, rankButton(RankActionPrev, i18n.T(language, "rank.button.prev"), period+" page "+strconv.Itoa(rank.Page-1)))
+	}
+	if len(mode) == 0 && rank.Page < rank.TotalPages {
+		buttons = append(buttons, rankButton(RankActionNext, i18n.T(language, "rank.button.next"), period+" page "+strconv.Itoa(rank.Page+1)))
+	}
+	nextPeriod := rankPeriods[0]
+	for i, rankPeriod := range rankPeriods {
+		if rankPeriod == period && i+1 < len(rankPeriods) {
+			nextPeriod = rankPeriods[i+1]
+		}
+	}
+	periodArgs := nextPeriod + mode
+	if len(mode) == 0 {
+		// A lone "all" argument means the full rank, so the first page is requested explicitly
+		periodArgs += " page 1"
+	}
+	buttons = append(buttons, rankButton(RankActionPeriod, i18n.T(language, "rank.button.period", "period", nextPeriod), periodArgs))
+	return append(blocks, slack.NewActionBlock(RankBlockPrefix+group+":"+channel, buttons...))
+}
+
+// rankButton returns a rank button, value holds the rank arguments it renders
+func rankButton(actionID string, text string, value string) *slack.ButtonBlockElement {
+	return slack.NewButtonBlockElement(actionID, value, slack.NewTextBlockObject(slack.PlainTextType, text, true, false))
+}
@@ -2,176 +2,327 @@ package commands
 
 import (
 	"log"
-	"sort"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+	"github.com/mvazquezc/karma-bot/pkg/settings"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
+	"github.com/mvazquezc/karma-bot/pkg/templates"
+	"github.com/mvazquezc/karma-bot/pkg/utils"
+	"github.com/slack-go/slack"
 )
 
+// rankPageSize number of words shown on each rank page
+const rankPageSize = 10
+
 // Commands type
 type Commands struct {
-	db *database.Database
+	db          *database.Database
+	api         *slackcache.Client
+	superAdmins SuperAdmins
+	// interactive is true when the bot receives Slack interactions, so messages can include buttons
+	interactive bool
 }
 
 // New Settings constructor
-func New(database *database.Database) Commands {
-	commands := Commands{db: database}
+func New(database *database.Database, api *slackcache.Client, superAdmins SuperAdmins) Commands {
+	commands := Commands{db: database, api: api, superAdmins: superAdmins}
 	return commands
 }
 
-// ProcessCommand processes a command
-func (cmd *Commands) ProcessCommand(channel string, who string, operation string, operationGroup string, operationArgs string) string {
+// EnableInteractivity adds buttons to the messages that support them, it must only be enabled when the
+// bot receives Slack interaction payloads
+func (cmd *Commands) EnableInteractivity() {
+	cmd.interactive = true
+}
+
+// language returns the language configured for a channel
+func (cmd *Commands) language(channel string) string {
+	return cmd.db.GetSettingValue(channel, "language")
+}
+
+// displayWord returns how a karma word is shown in messages, users are shown with their current name
+func (cmd *Commands) displayWord(word string) string {
+	return utils.DisplayWord(cmd.api, word)
+}
+
+// displayRank returns a copy of a rank page with its words as they are shown in messages
+func (cmd *Commands) displayRank(rank database.RankPage) database.RankPage {
+	entries := make([]database.RankEntry, len(rank.Entries))
+	for index, entry := range rank.Entries {
+		entry.Word = cmd.displayWord(entry.Word)
+		entries[index] = entry
+	}
+	rank.Entries = entries
+	return rank
+}
+
+// t returns a message in the language configured for a channel, args are pairs of placeholder names and values
+func (cmd *Commands) t(channel string, key string, args ...string) string {
+	return i18n.T(cmd.language(channel), key, args...)
+}
+
+// errorMessage returns the message of a validation error in the language configured for a channel
+func (cmd *Commands) errorMessage(channel string, err error) string {
+	return i18n.ErrorMessage(cmd.language(channel), err)
+}
+
+// sourceName returns where a setting, template or modifier value comes from in the language configured for a channel
+func (cmd *Commands) sourceName(channel string, source string) string {
+	return cmd.t(channel, "source."+source)
+}
+
+// ProcessCommand processes a command and returns its response, commandPrefix is the prefix the command was invoked
+// with (the channel prefix or the slash command) and is shown in usage messages, the channel prefix is used when empty
+func (cmd *Commands) ProcessCommand(channel string, who string, commandPrefix string, operation string, operationGroup string, operationArgs string) Response {
 	//trim spaces from the args
 	operationArgs = strings.TrimSpace(operationArgs)
+	// Karma words, mentions and keywords are folded the way the parser folds karma targets, templates, setting
+	// values and aliases are kept as typed
+	foldedArgs := parser.Normalize(operationArgs)
 	log.Printf("Processing operation: %s, operationGroup: %s, operationArgs: %s, in channel %s sent by user %s", operation, operationGroup, operationArgs, channel, who)
-	var commandOutput string
+	var response Response
 	switch operationGroup {
 	case "globalkarma":
 		if operation == "rank" {
-			commandOutput = cmd.getGlobalKarmaRank(operationArgs)
+			response = cmd.getGlobalKarmaRank(channel, foldedArgs)
+		}
+	case "givers":
+		if operation == "rank" {
+			response = cmd.getGiversRank(channel, foldedArgs)
 		}
 	case "karma":
 		if operation == "set" {
-			commandOutput = cmd.setKarma(channel, operationArgs, who)
+			response = cmd.setKarma(channel, foldedArgs, who)
 		} else if operation == "rank" {
-			commandOutput = cmd.getKarmaRank(channel, operationArgs)
+			response = cmd.getKarmaRank(channel, foldedArgs)
 		} else if operation == "del" {
-			commandOutput = cmd.delKarma(channel, operationArgs, who)
+			response = cmd.delKarma(channel, foldedArgs, who)
 		} else {
-			commandOutput = cmd.getKarma(channel, operationArgs)
+			response = cmd.getKarma(channel, foldedArgs)
+		}
+	case "profile":
+		if operation == "get" {
+			response = cmd.getProfile(channel, foldedArgs)
+		}
+	case "budget":
+		if operation == "get" {
+			response = cmd.getBudget(channel, who)
+		} else if operation == "del" {
+			response = cmd.delBudget(channel, foldedArgs, who)
+		}
+	case "audit":
+		if operation == "get" {
+			response = cmd.getAudit(channel, foldedArgs, who)
 		}
 	case "admin":
 		if operation == "set" {
-			commandOutput = cmd.setAdmin(channel, operationArgs, who)
+			response = cmd.setAdmin(channel, foldedArgs, who)
 		} else if operation == "get" {
-			_, commandOutput = cmd.getAdmins(channel)
+			_, adminsOutput := cmd.getAdmins(channel)
+			response = reply(adminsOutput)
 		} else {
-			commandOutput = cmd.delAdmin(channel, operationArgs, who)
+			response = cmd.delAdmin(channel, foldedArgs, who)
 		}
 	case "setting":
 		if operation == "set" {
-			commandOutput = cmd.setSetting(channel, operationArgs, who)
+			response = cmd.setSetting(channel, operationArgs, who)
+		} else if operation == "del" {
+			response = cmd.delSetting(channel, foldedArgs, who)
 		} else {
-			commandOutput = cmd.getSetting(channel, operationArgs)
+			response = cmd.getSetting(channel, foldedArgs)
+		}
+	case "template":
+		if operation == "set" {
+			response = cmd.setTemplate(channel, operationArgs, who)
+		} else if operation == "del" {
+			response = cmd.delTemplate(channel, foldedArgs, who)
+		} else if operation == "get" {
+			response = cmd.getTemplate(channel, foldedArgs, who)
+		}
+	case "modifier":
+		if operation == "set" {
+			response = cmd.setModifier(channel, foldedArgs, who)
+		} else if operation == "del" {
+			response = cmd.delModifier(channel, foldedArgs, who)
+		} else if operation == "get" {
+			response = cmd.getModifiers(channel)
 		}
 	case "alias":
 		if operation == "set" {
-			commandOutput = cmd.setAlias(channel, operationArgs, who)
+			response = cmd.setAlias(channel, operationArgs, who)
 		} else if operation == "get" {
 
-			commandOutput = cmd.getAlias(channel, operationArgs)
+			response = cmd.getAlias(channel, foldedArgs)
 		} else {
-			commandOutput = cmd.delAlias(channel, operationArgs, who)
+			response = cmd.delAlias(channel, operationArgs, who)
 		}
 	default:
 		log.Printf("Unknown operationGroup %s", operationGroup)
 		break
 	}
-	return commandOutput
+	return cmd.newResponse(channel, commandPrefix, response)
 }
 
-// usage: kb set setting setting_name setting_value
-func (cmd *Commands) setSetting(channel string, parameters string, who string) string {
-	var commandResult string
-	admins, _ := cmd.getAdmins(channel)
-	requesterIsAdmin := contains(admins, who)
-	if requesterIsAdmin {
+// settingScope returns where a setting command applies, the current channel or the whole workspace
+// when the parameters start with "workspace", and the remaining parameters
+func (cmd *Commands) settingScope(channel string, params []string) (scope string, scopeName string, remainingParams []string) {
+	if len(params) > 0 && parser.Normalize(params[0]) == "workspace" {
+		return database.WorkspaceScope, cmd.t(channel, "scope.workspace"), params[1:]
+	}
+	return channel, cmd.t(channel, "scope.channel"), params
+}
+
+// requiredRoleForScope returns the role needed to change settings on a scope, only super-admins change workspace settings
+func requiredRoleForScope(scope string) Role {
+	if scope == database.WorkspaceScope {
+		return RoleSuperAdmin
+	}
+	return RoleAdmin
+}
+
+// usage: kb set setting [workspace] setting_name setting_value
+func (cmd *Commands) setSetting(channel string, parameters string, who string) Response {
+	var commandResult Response
+	scope, scopeName, params := cmd.settingScope(channel, strings.Fields(parameters))
+	requesterHasRole := cmd.hasRole(channel, who, requiredRoleForScope(scope))
+	if requesterHasRole {
 		// We expect parameters to have something like "setting_name setting_value" so we need to check that
-		params := strings.Fields(parameters)
-		if len(params) != 2 {
-			log.Printf("Received more than 2 parameters. Params: %s", parameters)
-			commandResult = "Incorrect parameters. Usage kb set setting setting_name integer_setting_value :warning:"
+		if len(params) < 2 {
+			log.Printf("Received less than 2 parameters. Params: %s", parameters)
+			commandResult = failure(cmd.t(channel, "setting.set.usage"))
 		} else {
-			settingName := params[0]
-			settingValue := params[1]
-			// We need to ensure the setting is within the valid settings list
-			validSettings := []string{"notify_karma", "use_karma_emojis"}
-			validSetting := contains(validSettings, settingName)
+			settingName := parser.Normalize(params[0])
+			// List settings can be set as space separated values
+			settingValue := strings.Join(params[1:], " ")
+			// We need to ensure the setting is within the registered settings
+			setting, validSetting := settings.Get(settingName)
 			if validSetting {
-				// Convert string to int to ensure we received a setting value
-				_, err := strconv.Atoi(settingValue)
+				normalizedValue, err := setting.Validate(settingValue)
 				if err != nil {
-					log.Printf("Received incorrect setting value %s", settingValue)
-					commandResult = "Incorrect parameters. Usage kb set setting setting_name integer_setting_value :warning:"
+					log.Printf("Received incorrect setting value %s for setting %s: %s", settingValue, settingName, err)
+					commandResult = failure(cmd.t(channel, "setting.set.invalid_value", "value", settingValue, "setting", settingName, "error", cmd.errorMessage(channel, err)))
 				} else {
-					log.Printf("Received setting %s and setting value %s", settingName, settingValue)
-					cmd.db.SetSetting(channel, settingName, settingValue)
-					log.Printf("Setting %s configured to %s", settingName, settingValue)
-					commandResult = "User <@" + strings.ToUpper(who) + "> configured setting `" + settingName + "` to `" + settingValue + "` on this channel :white_check_mark:"
+					log.Printf("Received setting %s and setting value %s for scope %s", settingName, normalizedValue, scope)
+					previousValue := cmd.db.GetSetting(scope, settingName)
+					cmd.db.SetSetting(scope, settingName, normalizedValue)
+					cmd.audit(channel, who, "set setting", parameters, previousValue, normalizedValue)
+					log.Printf("Setting %s configured to %s", settingName, normalizedValue)
+					commandResult = reply(cmd.t(channel, "setting.set.done", "user", "<@"+strings.ToUpper(who)+">", "setting", settingName, "value", normalizedValue, "scope", scopeName))
 				}
 			} else {
 				log.Printf("Received incorrect setting %s", settingName)
-				commandResult = "Incorrect setting name, setting `" + settingName + "` is not a valid setting :warning:"
+				commandResult = failure(cmd.t(channel, "setting.invalid_name", "setting", settingName))
 			}
 		}
 	} else {
-		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-		commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to set settings on this channel :no_entry_sign:"
+		log.Printf("Requester user %s, has no permissions to set settings on scope %s. Operation canceled", who, scope)
+		commandResult = failure(cmd.t(channel, "setting.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">", "scope", scopeName))
 	}
 	return commandResult
 }
 
-// getSetting returns the value for a setting in a given channel
-// usage: kb get setting setting_name
-func (cmd *Commands) getSetting(channel string, parameters string) string {
+// usage: kb del setting [workspace] setting_name
+func (cmd *Commands) delSetting(channel string, parameters string, who string) Response {
+	var commandResult Response
+	scope, scopeName, params := cmd.settingScope(channel, strings.Fields(parameters))
+	requesterHasRole := cmd.hasRole(channel, who, requiredRoleForScope(scope))
+	if requesterHasRole {
+		if len(params) != 1 {
+			log.Printf("Received incorrect parameters. Params: %s", parameters)
+			commandResult = failure(cmd.t(channel, "setting.del.usage"))
+		} else {
+			settingName := params[0]
+			// We need to ensure the setting is within the registered settings
+			_, validSetting := settings.Get(settingName)
+			if !validSetting {
+				log.Printf("Received incorrect setting %s", settingName)
+				commandResult = failure(cmd.t(channel, "setting.invalid_name", "setting", settingName))
+			} else if previousValue := cmd.db.GetSetting(scope, settingName); len(previousValue) <= 0 {
+				log.Printf("Setting %s is not configured on scope %s", settingName, scope)
+				commandResult = failure(cmd.t(channel, "setting.del.not_configured", "setting", settingName, "scope", scopeName))
+			} else {
+				cmd.db.DelSetting(scope, settingName)
+				cmd.audit(channel, who, "del setting", parameters, previousValue, "")
+				value, source := cmd.db.GetEffectiveSetting(channel, settingName)
+				log.Printf("Setting %s deleted from scope %s, effective value is now %s from %s", settingName, scope, value, source)
+				commandResult = reply(cmd.t(channel, "setting.del.done", "user", "<@"+strings.ToUpper(who)+">", "setting", settingName, "scope", scopeName, "value", value, "source", cmd.sourceName(channel, source)))
+			}
+		}
+	} else {
+		log.Printf("Requester user %s, has no permissions to delete settings on scope %s. Operation canceled", who, scope)
+		commandResult = failure(cmd.t(channel, "setting.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">", "scope", scopeName))
+	}
+	return commandResult
+}
+
+// getSetting returns the effective value for settings in a given channel and where the value comes from,
+// all settings are listed when no setting is requested
+// usage: kb get setting [workspace] [setting_name...]
+func (cmd *Commands) getSetting(channel string, parameters string) Response {
 	log.Printf("Getting value for setting %s in channel %s", parameters, channel)
-	settings := strings.Fields(parameters)
+	scope, scopeName, settingNames := cmd.settingScope(channel, strings.Fields(parameters))
 	var commandResult string
-	for _, a := range settings {
-		settingValue := cmd.db.GetSetting(channel, a)
-		if len(settingValue) <= 0 {
+	if len(settingNames) == 0 {
+		commandResult = cmd.t(channel, "setting.get.title", "scope", scopeName)
+		settingNames = settings.Names()
+	}
+	for _, a := range settingNames {
+		setting, validSetting := settings.Get(a)
+		if !validSetting {
 			log.Printf("Setting %s does not exist", a)
-			commandResult += "Setting `" + a + "` is not configured\n"
-		} else {
-			log.Printf("Setting %s is configured to %s", a, settingValue)
-			commandResult += "Setting `" + a + "` is configured to `" + settingValue + "`\n"
+			commandResult += cmd.t(channel, "setting.get.invalid_name", "setting", a)
+			continue
 		}
+		settingValue, source := cmd.db.GetEffectiveSetting(scope, a)
+		log.Printf("Setting %s is %s from %s", a, settingValue, source)
+		commandResult += cmd.t(channel, "setting.get.value", "setting", a, "value", settingValue, "source", cmd.sourceName(channel, source), "usage", setting.Usage(cmd.language(channel)), "help", cmd.t(channel, "setting."+a))
 	}
-	return commandResult
+	return reply(commandResult)
 }
 
 // usage: kb del karma word
-func (cmd *Commands) delKarma(channel string, parameters string, who string) string {
-	var commandResult string
-	admins, _ := cmd.getAdmins(channel)
-	requesterIsAdmin := contains(admins, who)
-	if requesterIsAdmin {
+func (cmd *Commands) delKarma(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
 		// We expect parameters to have something like "word karmaValue" so we need to check that
 		params := strings.Fields(parameters)
 		if len(params) != 1 {
 			log.Printf("Received more than 2 parameters. Params: %s", parameters)
-			commandResult = "Incorrect parameters. Usage kb del karma word :warning:"
+			commandResult = failure(cmd.t(channel, "karma.del.usage"))
 		} else {
 			word := params[0]
 
 			log.Printf("Received word %s", word)
-			// Update karma adds/removes karma based on +x or -x where x is the number of points you want to add/substract
-			currentKarma := cmd.db.GetCurrentKarma(channel, word)
-			// By multiplying the currentKarma by -1 we get the negative (or positive) karma we need to set for resetting the counter to 0
-			finalKarma, _, _ := cmd.db.UpdateKarma(channel, word, currentKarma*-1, who, time.Now().Unix())
+			previousKarma := cmd.db.GetCurrentKarma(channel, word)
+			finalKarma := cmd.db.ResetKarma(channel, word, who, time.Now().Unix())
+			cmd.audit(channel, who, "del karma", parameters, auditKarma(previousKarma), finalKarma)
 			log.Printf("Karma for word %s reseted to %s", word, finalKarma)
-			commandResult = "User <@" + strings.ToUpper(who) + "> reseted karma for word `" + word + "` on this channel :white_check_mark:"
+			commandResult = reply(utils.RenderMessage(*cmd.db, channel, "karma_reset", templates.Data{Word: cmd.displayWord(word), Giver: "<@" + strings.ToUpper(who) + ">"}))
 		}
 	} else {
 		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-		commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to reset karma on this channel :no_entry_sign:"
+		commandResult = failure(cmd.t(channel, "karma.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
 	}
 	return commandResult
 }
 
 // usage: kb set karma word karmaValue
-func (cmd *Commands) setKarma(channel string, parameters string, who string) string {
-	var commandResult string
-	admins, _ := cmd.getAdmins(channel)
-	requesterIsAdmin := contains(admins, who)
-	if requesterIsAdmin {
+func (cmd *Commands) setKarma(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
 		// We expect parameters to have something like "word karmaValue" so we need to check that
 		params := strings.Fields(parameters)
 		if len(params) != 2 {
 			log.Printf("Received more than 2 parameters. Params: %s", parameters)
-			commandResult = "Incorrect parameters. Usage kb set karma word integer :warning:"
+			commandResult = failure(cmd.t(channel, "karma.set.usage"))
 		} else {
 			word := params[0]
 			karmaValue := params[1]
@@ -179,23 +330,26 @@ func (cmd *Commands) setKarma(channel string, parameters string, who string) str
 			karmaValueInt, err := strconv.Atoi(karmaValue)
 			if err != nil {
 				log.Printf("Received incorrect karma value %s", karmaValue)
-				commandResult = "Incorrect parameters. Usage kb set karma word integer :warning:"
+				commandResult = failure(cmd.t(channel, "karma.set.usage"))
 			} else {
 				log.Printf("Received word %s and karma value %s", word, karmaValue)
-				finalKarma, _, _ := cmd.db.UpdateKarma(channel, word, karmaValueInt, who, time.Now().Unix())
+				previousKarma := cmd.db.GetCurrentKarma(channel, word)
+				finalKarma, _, finalKarmaInt := cmd.db.UpdateKarma(channel, word, karmaValueInt, who, time.Now().Unix())
+				cmd.audit(channel, who, "set karma", parameters, auditKarma(previousKarma), finalKarma)
 				log.Printf("Karma for word %s updated to %s", word, finalKarma)
-				commandResult = "User <@" + strings.ToUpper(who) + "> set karma for word `" + word + "` to `" + finalKarma + "` on this channel :white_check_mark:"
+				templateData := templates.Data{Word: cmd.displayWord(word), Karma: finalKarmaInt, Delta: karmaValueInt, Giver: "<@" + strings.ToUpper(who) + ">"}
+				commandResult = reply(utils.RenderMessage(*cmd.db, channel, "karma_set", templateData))
 			}
 		}
 	} else {
 		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-		commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to set karma on this channel :no_entry_sign:"
+		commandResult = failure(cmd.t(channel, "karma.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
 	}
 	return commandResult
 }
 
 // usage: kb get karma word/s
-func (cmd *Commands) getKarma(channel string, args string) string {
+func (cmd *Commands) getKarma(channel string, args string) Response {
 	log.Printf("Getting karma for words %s in channel %s", args, channel)
 	words := strings.Fields(args)
 	var commandResult string
@@ -204,121 +358,364 @@ func (cmd *Commands) getKarma(channel string, args string) string {
 		alias := cmd.db.GetAlias(a, channel)
 		if len(alias) > 0 {
 			log.Printf("Word %s has an alias configured, using alias %s", a, alias)
-			a = alias
+			a = parser.Normalize(alias)
 		}
-		karmaValue := cmd.db.GetCurrentKarma(channel, a)
-		if karmaValue == -256256 {
-			karmaValue = 0
+		karmaValue := cmd.db.GetDisplayKarma(channel, a)
+		commandResult += utils.RenderMessage(*cmd.db, channel, "karma_value", templates.Data{Word: cmd.displayWord(a), Karma: karmaValue, Global: cmd.db.GetGlobalKarma(a)}) + "\n"
+	}
+	return reply(commandResult)
+}
+
+// userIDRegex matches the Slack user IDs in mentions, command arguments are folded so IDs are lowercase
+var userIDRegex = regexp.MustCompile("^[a-z0-9]+$")
+
+// usage: kb get profile @user
+func (cmd *Commands) getProfile(channel string, user string) Response {
+	if !strings.HasPrefix(user, "<@") || !strings.HasSuffix(user, ">") {
+		log.Printf("No user detected, received %s as user", user)
+		return failure(cmd.t(channel, "profile.usage"))
+	}
+	userID := strings.TrimSuffix(strings.TrimPrefix(user, "<@"), ">")
+	if !userIDRegex.MatchString(userID) {
+		log.Printf("Invalid user ID %s received as user", userID)
+		return failure(cmd.t(channel, "profile.usage"))
+	}
+	// Resolve the user the same way karma is granted, so the profile matches the karma the user accumulates
+	word := utils.GetUserKarmaWord(*cmd.db, user, channel)
+	log.Printf("Getting profile for user %s (%s) in channel %s", userID, word, channel)
+
+	channelKarma := cmd.db.GetDisplayKarma(channel, word)
+	globalKarma := cmd.db.GetGlobalKarma(word)
+	rankPosition := "-"
+	rank := cmd.db.GetKarmaRank(channel, 1, 0, false)
+	for _, entry := range rank.Entries {
+		if entry.Word == word {
+			rankPosition = "#" + strconv.Itoa(entry.Position)
+			break
 		}
-		result := strconv.Itoa(karmaValue)
-		commandResult += "`" + a + "` has `" + result + "` karma points!\n"
+	}
+	givenPositive, givenNegative := cmd.db.GetKarmaGiven(channel, userID, 0)
+	receivedPositive, receivedNegative := cmd.db.GetKarmaReceived(channel, word)
+
+	var boostedWords []string
+	for _, entry := range cmd.db.GetTopBoostedWords(channel, userID, 3) {
+		boostedWords = append(boostedWords, "`"+cmd.displayWord(entry.Word)+" ("+strconv.Itoa(entry.Karma)+")`")
+	}
+	var boosters []string
+	for _, entry := range cmd.db.GetTopBoosters(channel, word, 3) {
+		boosters = append(boosters, "<@"+strings.ToUpper(entry.Word)+"> ("+strconv.Itoa(entry.Karma)+")")
+	}
+	streak := karmaStreak(cmd.db.GetKarmaDays(channel, word), time.Now())
+
+	commandResult := cmd.t(channel, "profile.title", "user", "<@"+strings.ToUpper(userID)+">", "word", cmd.displayWord(word))
+	commandResult += cmd.t(channel, "profile.karma", "karma", strconv.Itoa(channelKarma), "global", strconv.Itoa(globalKarma))
+	commandResult += cmd.t(channel, "profile.rank", "position", rankPosition, "total", strconv.Itoa(rank.TotalWords))
+	commandResult += cmd.t(channel, "profile.given", "positive", strconv.Itoa(givenPositive), "negative", strconv.Itoa(givenNegative))
+	commandResult += cmd.t(channel, "profile.received", "positive", strconv.Itoa(receivedPositive), "negative", strconv.Itoa(receivedNegative))
+	if len(boostedWords) > 0 {
+		commandResult += cmd.t(channel, "profile.boosted_words", "words", strings.Join(boostedWords, ", "))
+	}
+	if len(boosters) > 0 {
+		commandResult += cmd.t(channel, "profile.boosters", "users", strings.Join(boosters, ", "))
+	}
+	commandResult += cmd.t(channel, "profile.streak", "days", strconv.Itoa(streak))
+	if streak >= 3 {
+		commandResult += " :fire:"
+	}
+	return reply(commandResult + "\n")
+}
+
+// karmaStreak returns the number of consecutive days receiving karma, days must be sorted newest first
+// the streak is still alive if the last karma was received yesterday
+func karmaStreak(days []string, now time.Time) int {
+	streak := 0
+	expectedDay := now
+	for i, day := range days {
+		if i == 0 && day != expectedDay.Format("2006-01-02") {
+			// Give the user until the end of today to keep the streak
+			expectedDay = expectedDay.AddDate(0, 0, -1)
+		}
+		if day != expectedDay.Format("2006-01-02") {
+			break
+		}
+		streak++
+		expectedDay = expectedDay.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+// usage: kb get budget
+func (cmd *Commands) getBudget(channel string, who string) Response {
+	log.Printf("Getting karma budget for user %s in channel %s", who, channel)
+	budget := cmd.db.GetKarmaBudget(channel, who)
+	return reply(cmd.t(channel, "budget.get", "user", "<@"+strings.ToUpper(who)+">", "budget", utils.FormatKarmaBudget(budget, cmd.language(channel))))
+}
+
+// usage: kb del budget @user
+func (cmd *Commands) delBudget(channel string, user string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleModerator)
+	if requesterHasRole {
+		userID := strings.TrimSuffix(strings.TrimPrefix(user, "<@"), ">")
+		if strings.HasPrefix(user, "<@") && strings.HasSuffix(user, ">") && userIDRegex.MatchString(userID) {
+			user = userID
+			budget := cmd.db.GetKarmaBudget(channel, user)
+			cmd.db.ResetKarmaBudget(channel, user, time.Now().Unix())
+			cmd.audit(channel, who, "del budget", "<@"+user+">", "+"+strconv.Itoa(budget.PositiveUsed)+"/-"+strconv.Itoa(budget.NegativeUsed)+" used", "reset")
+			log.Printf("Karma budget for user %s reseted in channel %s by user %s", user, channel, who)
+			commandResult = reply(cmd.t(channel, "budget.del.done", "user", "<@"+strings.ToUpper(who)+">", "target", "<@"+strings.ToUpper(user)+">"))
+		} else {
+			log.Printf("No user detected, received %s as user", user)
+			commandResult = failure(cmd.t(channel, "budget.del.usage"))
+		}
+	} else {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		commandResult = failure(cmd.t(channel, "budget.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
 	}
 	return commandResult
 }
 
-// usage: kb rank karma [all], we return top10 words by default
-func (cmd *Commands) getKarmaRank(channel string, args string) string {
+// usage: kb rank karma [today|week|month|year|all] [all|bottom|page n], we return top10 words by default
+func (cmd *Commands) getKarmaRank(channel string, args string) Response {
+	language := cmd.language(channel)
 	log.Printf("Getting karma rank in channel %s", channel)
-	var commandResult string
-	getAll := false
-	if args == "all" {
-		getAll = true
-	}
-	rank := cmd.db.GetKarmaRank(channel, getAll)
-	// Rank is an ordered map, we need to order it (https://code-maven.com/slides/golang/sort-map-by-value)
-	ranks := make([]string, 0, len(rank))
-	for word := range rank {
-		ranks = append(ranks, word)
-	}
-	sort.Slice(ranks, func(i, j int) bool {
-		return rank[ranks[i]] > rank[ranks[j]]
-	})
-	commandResult = ":trophy: Karma Rank :trophy: \n"
-	var karmaValue string
-	for _, word := range ranks {
-		karmaValue = strconv.Itoa(rank[word])
-		commandResult += "  `" + word + " (" + karmaValue + ")`\n"
+	period, page, pageSize, bottom, validArgs := parseRankArgs(args)
+	if !validArgs {
+		log.Printf("Received incorrect rank parameters %s", args)
+		return failure(i18n.T(language, "rank.karma.usage"))
 	}
-	return commandResult
+	var rank database.RankPage
+	if period == "all" {
+		rank = cmd.db.GetKarmaRank(channel, page, pageSize, bottom)
+	} else {
+		since, _ := periodStart(period, time.Now())
+		rank = cmd.db.GetKarmaRankSince(channel, since, page, pageSize, bottom)
+	}
+	rank = cmd.displayRank(rank)
+	var blocks []slack.Block
+	if cmd.useBlocks(channel) {
+		blocks = cmd.rankBlocks(language, i18n.T(language, "rank.karma.title"), i18n.T(language, "rank.karma.window", "period", period), rank, false)
+		blocks = cmd.appendRankActions(language, blocks, channel, "karma", period, rank, pageSize, bottom)
+	}
+	return rankResponse(renderRank(language, i18n.T(language, "rank.karma.text_title", "period", period), "{prefix} rank karma"+rankPeriodArg(period), rank), blocks, pageSize)
 }
 
-// usage: kb rank globalkarma [all], we return top10 words by default
-func (cmd *Commands) getGlobalKarmaRank(args string) string {
-	var commandResult string
-	getAll := false
-	if args == "all" {
-		getAll = true
-	}
-	rank := cmd.db.GetGlobalKarmaRank(getAll)
-	// Rank is an ordered map, we need to order it (https://code-maven.com/slides/golang/sort-map-by-value)
-	ranks := make([]string, 0, len(rank))
-	for word := range rank {
-		ranks = append(ranks, word)
-	}
-	sort.Slice(ranks, func(i, j int) bool {
-		return rank[ranks[i]] > rank[ranks[j]]
-	})
-	commandResult = ":trophy: Global Karma Rank :trophy: \n"
-	var karmaValue string
-	for _, word := range ranks {
-		karmaValue = strconv.Itoa(rank[word])
-		commandResult += "  `" + word + " (" + karmaValue + ")`\n"
+// usage: kb rank globalkarma [today|week|month|year|all] [all|bottom|page n], we return top10 words by default
+func (cmd *Commands) getGlobalKarmaRank(channel string, args string) Response {
+	language := cmd.language(channel)
+	period, page, pageSize, bottom, validArgs := parseRankArgs(args)
+	if !validArgs {
+		log.Printf("Received incorrect rank parameters %s", args)
+		return failure(i18n.T(language, "rank.globalkarma.usage"))
+	}
+	var rank database.RankPage
+	if period == "all" {
+		rank = cmd.db.GetGlobalKarmaRank(page, pageSize, bottom)
+	} else {
+		since, _ := periodStart(period, time.Now())
+		rank = cmd.db.GetGlobalKarmaRankSince(since, page, pageSize, bottom)
+	}
+	rank = cmd.displayRank(rank)
+	var blocks []slack.Block
+	if cmd.useBlocks(channel) {
+		blocks = cmd.rankBlocks(language, i18n.T(language, "rank.globalkarma.title"), i18n.T(language, "rank.globalkarma.window", "period", period), rank, false)
+		blocks = cmd.appendRankActions(language, blocks, channel, "globalkarma", period, rank, pageSize, bottom)
+	}
+	return rankResponse(renderRank(language, i18n.T(language, "rank.globalkarma.text_title", "period", period), "{prefix} rank globalkarma"+rankPeriodArg(period), rank), blocks, pageSize)
+}
+
+// rankResponse returns the response for a rank, full ranks (no page size) are sent by DM since they can be very long
+func rankResponse(text string, blocks []slack.Block, pageSize int) Response {
+	response := Response{Text: text, Blocks: blocks}
+	if pageSize == 0 {
+		response.Visibility = VisibilityDM
+	}
+	return response
+}
+
+// usage: kb rank givers [today|week|month|year|all], we return top10 givers of positive and negative karma
+func (cmd *Commands) getGiversRank(channel string, args string) Response {
+	language := cmd.language(channel)
+	log.Printf("Getting karma givers rank in channel %s", channel)
+	period := args
+	if len(period) <= 0 {
+		period = "all"
+	}
+	since, validPeriod := periodStart(period, time.Now())
+	if !validPeriod {
+		log.Printf("Received incorrect period %s", period)
+		return failure(i18n.T(language, "rank.givers.usage"))
+	}
+	positiveRank := cmd.db.GetGiversRank(channel, since, false)
+	negativeRank := cmd.db.GetGiversRank(channel, since, true)
+	commandResult := i18n.T(language, "rank.givers.text_title", "period", period)
+	commandResult += i18n.T(language, "rank.givers.given_text") + renderGivers(language, positiveRank, "+")
+	commandResult += i18n.T(language, "rank.givers.taken_text") + renderGivers(language, negativeRank, "-")
+	var blocks []slack.Block
+	if cmd.useBlocks(channel) {
+		window := i18n.T(language, "rank.givers.window", "period", period)
+		blocks = append(cmd.rankBlocks(language, i18n.T(language, "rank.givers.given"), window, giversRankPage(positiveRank), true), slack.NewDividerBlock())
+		blocks = append(blocks, cmd.rankBlocks(language, i18n.T(language, "rank.givers.taken"), window, giversRankPage(negativeRank), true)...)
+	}
+	return Response{Text: commandResult, Blocks: blocks}
+}
+
+// giversRankPage returns the top10 page of a givers rank
+func giversRankPage(rank []database.RankEntry) database.RankPage {
+	rankPage := database.RankPage{Entries: rank, Page: 1, TotalPages: 1, TotalWords: len(rank)}
+	if len(rank) > rankPageSize {
+		rankPage.Entries = rank[:rankPageSize]
+	}
+	return rankPage
+}
+
+// renderGivers returns the message for the top10 users of a givers rank
+func renderGivers(language string, rank []database.RankEntry, sign string) string {
+	if len(rank) == 0 {
+		return i18n.T(language, "rank.givers.nobody")
+	}
+	if len(rank) > rankPageSize {
+		rank = rank[:rankPageSize]
+	}
+	var givers string
+	for _, entry := range rank {
+		givers += "  " + strconv.Itoa(entry.Position) + ". <@" + strings.ToUpper(entry.Word) + "> (`" + sign + strconv.Itoa(entry.Karma) + "`)\n"
+	}
+	return givers
+}
+
+// periodStart returns the unix timestamp where a period (today, week, month, year or all) starts
+func periodStart(period string, now time.Time) (since int64, validPeriod bool) {
+	switch period {
+	case "today":
+		year, month, day := now.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Unix(), true
+	case "week":
+		return now.AddDate(0, 0, -7).Unix(), true
+	case "month":
+		return now.AddDate(0, -1, 0).Unix(), true
+	case "year":
+		return now.AddDate(-1, 0, 0).Unix(), true
+	case "all":
+		return 0, true
+	}
+	return 0, false
+}
+
+// parseRankArgs parses the optional rank arguments: a period (today, week, month, year or all) followed by all, bottom or page n
+func parseRankArgs(args string) (period string, page int, pageSize int, bottom bool, validArgs bool) {
+	params := strings.Fields(args)
+	period = "all"
+	if len(params) > 0 {
+		if _, validPeriod := periodStart(params[0], time.Now()); validPeriod && (params[0] != "all" || len(params) > 1) {
+			period = params[0]
+			params = params[1:]
+		}
+	}
+	page = 1
+	pageSize = rankPageSize
+	switch {
+	case len(params) == 0:
+		validArgs = true
+	case len(params) == 1 && params[0] == "all":
+		pageSize = 0
+		validArgs = true
+	case len(params) == 1 && params[0] == "bottom":
+		bottom = true
+		validArgs = true
+	case len(params) == 2 && params[0] == "page":
+		pageNumber, err := strconv.Atoi(params[1])
+		if err == nil && pageNumber > 0 {
+			page = pageNumber
+			validArgs = true
+		}
+	}
+	return period, page, pageSize, bottom, validArgs
+}
+
+// rankPeriodArg returns the rank command argument for a period, the whole history is the default so it is omitted
+func rankPeriodArg(period string) string {
+	if period == "all" {
+		return ""
+	}
+	return " " + period
+}
+
+// renderRank returns the message for a rank page, the top 3 positions get a medal
+func renderRank(language string, title string, command string, rank database.RankPage) string {
+	commandResult := title
+	if rank.TotalWords == 0 {
+		return commandResult + i18n.T(language, "rank.empty") + "\n"
+	}
+	medals := map[int]string{1: ":first_place_medal:", 2: ":second_place_medal:", 3: ":third_place_medal:"}
+	for _, entry := range rank.Entries {
+		position := strconv.Itoa(entry.Position)
+		karmaValue := strconv.Itoa(entry.Karma)
+		medal, hasMedal := medals[entry.Position]
+		if !hasMedal {
+			medal = ":small_blue_diamond:"
+		}
+		commandResult += "  " + medal + " `" + position + ". " + entry.Word + " (" + karmaValue + ")`\n"
+	}
+	if rank.TotalPages > 1 {
+		commandResult += i18n.T(language, "rank.pages", "page", strconv.Itoa(rank.Page), "pages", strconv.Itoa(rank.TotalPages), "command", command) + "\n"
 	}
 	return commandResult
 }
 
 // usage: kb set alias word alias
-func (cmd *Commands) setAlias(channel string, parameters string, who string) string {
-	var commandResult string
-	admins, _ := cmd.getAdmins(channel)
-	requesterIsAdmin := contains(admins, who)
-	if requesterIsAdmin {
+func (cmd *Commands) setAlias(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleModerator)
+	if requesterHasRole {
 		// We expect parameters to have something like "word alias" so we need to check that
 		params := strings.Fields(parameters)
 		if len(params) != 2 {
 			log.Printf("Received more than 2 parameters. Params: %s", parameters)
-			commandResult = "Incorrect parameters. Usage kb set alias word alias :warning:"
+			commandResult = failure(cmd.t(channel, "alias.set.usage"))
 		} else {
-			word := params[0]
+			// The word is a user mention or a karma word, the alias is kept as typed
+			word := parser.Normalize(params[0])
 			alias := params[1]
 			log.Printf("Received word %s and alias %s", word, alias)
 			if alias != word {
 				aliasCreated := cmd.db.SetAlias(word, alias, channel)
 				if aliasCreated == 0 {
 					log.Printf("Alias %s configured for word %s", alias, word)
-					commandResult = "User <@" + strings.ToUpper(who) + "> configured alias `" + alias + "` for word `" + word + "` on this channel :white_check_mark:"
+					cmd.audit(channel, who, "set alias", parameters, "", alias)
+					commandResult = reply(cmd.t(channel, "alias.set.done", "user", "<@"+strings.ToUpper(who)+">", "alias", alias, "word", word))
 				} else if aliasCreated == 1 {
 					log.Printf("Word %s already has an alias", word)
-					commandResult = "Word `" + word + "` already has an alias on this channel :warning:"
+					commandResult = failure(cmd.t(channel, "alias.set.exists", "word", word))
 				} else {
 					log.Printf("Word %s is already in use as an alias in this channel, operation not permitted", word)
-					commandResult = "Word `" + word + "` is already in use as an alias in this channel, operation not permitted :no_entry_sign:"
+					commandResult = failure(cmd.t(channel, "alias.set.in_use", "word", word))
 				}
 			} else {
 				log.Printf("Invalid alias %s for word %s", alias, word)
-				commandResult = "Invalid alias `" + alias + "` for word `" + word + "` :warning:"
+				commandResult = failure(cmd.t(channel, "alias.invalid", "alias", alias, "word", word))
 			}
 		}
 	} else {
 		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-		commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to set alias on this channel :no_entry_sign:"
+		commandResult = failure(cmd.t(channel, "alias.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
 	}
 	return commandResult
 }
 
 // usage: kb del alias word alias
-func (cmd *Commands) delAlias(channel string, parameters string, who string) string {
-	var commandResult string
-	admins, _ := cmd.getAdmins(channel)
-	requesterIsAdmin := contains(admins, who)
-	if requesterIsAdmin {
+func (cmd *Commands) delAlias(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleModerator)
+	if requesterHasRole {
 		// We expect parameters to have something like "word alias" so we need to check that
 		params := strings.Fields(parameters)
 		if len(params) != 2 {
 			log.Printf("Received more than 2 parameters. Params: %s", parameters)
-			commandResult = "Incorrect parameters. Usage kb del alias word alias :warning:"
+			commandResult = failure(cmd.t(channel, "alias.del.usage"))
 		} else {
-			word := params[0]
+			// The word is a user mention or a karma word, the alias is kept as typed
+			word := parser.Normalize(params[0])
 			alias := params[1]
 			log.Printf("Received word %s and alias %s", word, alias)
 			if alias != word {
@@ -326,26 +723,27 @@ func (cmd *Commands) delAlias(channel string, parameters string, who string) str
 				if len(aliasExist) > 0 {
 					log.Printf("Alias exist for the word %s", aliasExist)
 					cmd.db.DelAlias(channel, word, alias)
+					cmd.audit(channel, who, "del alias", parameters, aliasExist, "")
 					log.Printf("Alias %s deleted for word %s", alias, word)
-					commandResult = "User <@" + strings.ToUpper(who) + "> deleted alias `" + alias + "` for word `" + word + "` on this channel :white_check_mark:"
+					commandResult = reply(cmd.t(channel, "alias.del.done", "user", "<@"+strings.ToUpper(who)+">", "alias", alias, "word", word))
 				} else {
 					log.Printf("Alias %s does not exist for word %s", alias, word)
-					commandResult = "Alias `" + alias + "` does not exist for word `" + word + "` :warning:"
+					commandResult = failure(cmd.t(channel, "alias.del.not_found", "alias", alias, "word", word))
 				}
 			} else {
 				log.Printf("Invalid alias %s for word %s", alias, word)
-				commandResult = "Invalid alias `" + alias + "` for word `" + word + "` :warning:"
+				commandResult = failure(cmd.t(channel, "alias.invalid", "alias", alias, "word", word))
 			}
 		}
 	} else {
 		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-		commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to delete alias on this channel :no_entry_sign:"
+		commandResult = failure(cmd.t(channel, "alias.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
 	}
 	return commandResult
 }
 
 // usage: kb get alias word
-func (cmd *Commands) getAlias(channel string, parameters string) string {
+func (cmd *Commands) getAlias(channel string, parameters string) Response {
 	log.Printf("Getting alias for word %s in channel %s", parameters, channel)
 	words := strings.Fields(parameters)
 	var commandResult string
@@ -353,18 +751,18 @@ func (cmd *Commands) getAlias(channel string, parameters string) string {
 		alias := cmd.db.GetAlias(a, channel)
 		if len(alias) <= 0 {
 			log.Printf("Setting %s does not exist", a)
-			commandResult += "Word `" + a + "` has no alias configured\n"
+			commandResult += cmd.t(channel, "alias.get.none", "word", a)
 		} else {
 			log.Printf("Word %s has alias %s configured", a, alias)
-			commandResult += "Word `" + a + "` has alias `" + alias + "` configured\n"
+			commandResult += cmd.t(channel, "alias.get.value", "word", a, "alias", alias)
 		}
 	}
-	return commandResult
+	return reply(commandResult)
 }
 
-// usage: kb del admin @adminuser
-func (cmd *Commands) delAdmin(channel string, user string, who string) string {
-	var commandResult string
+// usage: kb del admin @user
+func (cmd *Commands) delAdmin(channel string, user string, who string) Response {
+	var commandResult Response
 	admins, _ := cmd.getAdmins(channel)
 	if strings.HasPrefix(user, "<@") && strings.HasSuffix(user, ">") {
 		log.Printf("Detected user %s, removing special chars", user)
@@ -372,74 +770,105 @@ func (cmd *Commands) delAdmin(channel string, user string, who string) string {
 		user = strings.Replace(user, ">", "", -1)
 		user = strings.Replace(user, "@", "", -1)
 		log.Printf("Final user: %s", user)
-		if len(admins) == 0 {
+		if !userIDRegex.MatchString(user) {
+			log.Printf("Invalid user ID %s received as user", user)
+			commandResult = failure(cmd.t(channel, "admin.del.usage"))
+		} else if len(admins) == 0 {
 			log.Println("Channel has no admins")
-			commandResult = "Channel has no admins configured. Deletion canceled. :warning:"
+			commandResult = failure(cmd.t(channel, "admin.del.no_admins"))
+		} else if !cmd.hasRole(channel, who, requiredRoleToManage(RoleModerator)) {
+			// Moderators are the lowest role that can be deleted, requesters that cannot delete them cannot delete anyone
+			log.Printf("Requester user %s has no permissions to delete admins on channel %s. Operation canceled", who, channel)
+			commandResult = failure(cmd.t(channel, "admin.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">", "role", RoleModerator.String()))
 		} else {
-			// Check if user requesting an admin addition is admin for the channel
-			requesterIsAdmin := contains(admins, who)
-			if requesterIsAdmin {
-				// Check that the user being deleted from admin is admin already
-				adminAlreadyExists := contains(admins, user)
-				if adminAlreadyExists {
-					log.Printf("User %s is already admin for channel %s, deleting it from admins users", user, channel)
-					cmd.db.DeleteAdmin(channel, user)
-					commandResult = "User <@" + strings.ToUpper(user) + "> deleted from admins for this channel :white_check_mark:"
-				} else {
-					log.Printf("User %s is not configured as admin for channel %s. Deletion canceled.", user, channel)
-					commandResult = "User <@" + strings.ToUpper(user) + "> is not admin for this channel. Deletion canceled. :warning:"
-				}
+			userRole := roleNames[cmd.db.GetRole(channel, user)]
+			if userRole == RoleNone {
+				log.Printf("User %s is not configured as admin for channel %s. Deletion canceled.", user, channel)
+				commandResult = failure(cmd.t(channel, "admin.del.not_admin", "user", "<@"+strings.ToUpper(user)+">"))
+			} else if cmd.hasRole(channel, who, requiredRoleToManage(userRole)) {
+				log.Printf("User %s is %s for channel %s, deleting it from admins users", user, userRole, channel)
+				cmd.db.DeleteAdmin(channel, user)
+				cmd.audit(channel, who, "del admin", "<@"+user+">", userRole.String(), "")
+				commandResult = reply(cmd.t(channel, "admin.del.done", "user", "<@"+strings.ToUpper(user)+">"))
 			} else {
-				log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-				commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to delete admins from this channel :no_entry_sign:"
+				log.Printf("Requester user %s has no permissions to delete %s on channel %s. Operation canceled", who, userRole, channel)
+				commandResult = failure(cmd.t(channel, "admin.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">", "role", userRole.String()))
 			}
 		}
 	} else {
 		log.Printf("No user detected, received %s as user", user)
-		commandResult = "No user detected. Usage kb del admin @user :warning:"
+		commandResult = failure(cmd.t(channel, "admin.del.usage"))
 	}
 	return commandResult
 }
 
-// usage: kb set admin @user
-func (cmd *Commands) setAdmin(channel string, user string, who string) string {
-	//if not admin exists for a channel, the first user can set user as himself
-	//if an admin already exists, only the admin can set other admins
-	var commandResult string
+// usage: kb set admin @user [owner|admin|moderator]
+func (cmd *Commands) setAdmin(channel string, parameters string, who string) Response {
+	//if no admins exist for a channel, the first user can set an owner, unless the workspace has super-admins,
+	//in that case only super-admins can set the first owner
+	//if admins already exist, owners manage owners and admins, and admins manage moderators
+	var commandResult Response
 	admins, _ := cmd.getAdmins(channel)
-	if strings.HasPrefix(user, "<@") && strings.HasSuffix(user, ">") {
-		log.Printf("Detected user %s, removing special chars", user)
-		user = strings.Replace(user, "<", "", -1)
-		user = strings.Replace(user, ">", "", -1)
-		user = strings.Replace(user, "@", "", -1)
-		log.Printf("Final user: %s", user)
-		if len(admins) == 0 {
-			log.Println("No admins exists, we can create one")
-			cmd.db.CreateAdmin(channel, user)
-			log.Printf("Admin %s configured as first admin for channel %s", user, channel)
-			commandResult = "User <@" + strings.ToUpper(user) + "> configured as admin :white_check_mark:"
+	params := strings.Fields(parameters)
+	if len(params) < 1 || len(params) > 2 || !strings.HasPrefix(params[0], "<@") || !strings.HasSuffix(params[0], ">") {
+		log.Printf("No user detected, received %s as parameters", parameters)
+		return failure(cmd.t(channel, "admin.set.usage"))
+	}
+	user := params[0]
+	log.Printf("Detected user %s, removing special chars", user)
+	user = strings.Replace(user, "<", "", -1)
+	user = strings.Replace(user, ">", "", -1)
+	user = strings.Replace(user, "@", "", -1)
+	log.Printf("Final user: %s", user)
+	if !userIDRegex.MatchString(user) {
+		log.Printf("Invalid user ID %s received as user", user)
+		return failure(cmd.t(channel, "admin.set.usage"))
+	}
+	roleName := "admin"
+	if len(admins) == 0 {
+		// The first admin of a channel is its owner unless a role is given
+		roleName = "owner"
+	}
+	if len(params) == 2 {
+		roleName = params[1]
+	}
+	role, validRole := roleNames[roleName]
+	if !validRole {
+		log.Printf("Received incorrect role %s", roleName)
+		return failure(cmd.t(channel, "admin.set.invalid_role", "role", roleName))
+	}
+	if len(admins) == 0 && !cmd.superAdmins.configured() && role != RoleOwner {
+		log.Printf("No admins exists, the first admin must be an owner, received %s", role)
+		commandResult = failure(cmd.t(channel, "admin.set.first_owner_required", "role", role.String()))
+	} else if len(admins) == 0 && !cmd.superAdmins.configured() {
+		log.Println("No admins exists, we can create one")
+		cmd.db.CreateAdmin(channel, user, RoleOwner.String())
+		cmd.audit(channel, who, "set admin", parameters, "", RoleOwner.String())
+		log.Printf("Admin %s configured as first owner for channel %s", user, channel)
+		commandResult = reply(cmd.t(channel, "admin.set.first_owner", "user", "<@"+strings.ToUpper(user)+">"))
+	} else if cmd.hasRole(channel, who, requiredRoleToManage(role)) {
+		currentRole := roleNames[cmd.db.GetRole(channel, user)]
+		if currentRole == role {
+			log.Printf("User %s is already %s for channel %s", user, role, channel)
+			commandResult = failure(cmd.t(channel, "admin.set.already", "user", "<@"+strings.ToUpper(user)+">", "role", role.String()))
+		} else if currentRole != RoleNone && !cmd.hasRole(channel, who, requiredRoleToManage(currentRole)) {
+			log.Printf("Requester user %s has no permissions to change %s on channel %s. Operation canceled", who, currentRole, channel)
+			commandResult = failure(cmd.t(channel, "admin.set.no_permissions_change", "user", "<@"+strings.ToUpper(who)+">", "role", currentRole.String()))
 		} else {
-			// Check if user requesting an admin addition is admin for the channel
-			requesterIsAdmin := contains(admins, who)
-			if requesterIsAdmin {
-				// Check that the user being configured as admin is not admin already
-				adminAlreadyExists := contains(admins, user)
-				if adminAlreadyExists {
-					log.Printf("User %s is already admin for channel %s", user, channel)
-					commandResult = "User <@" + strings.ToUpper(user) + "> is already an admin for this channel :warning:"
-				} else {
-					cmd.db.CreateAdmin(channel, user)
-					log.Printf("User %s configured admin for channel %s by user %s", user, channel, who)
-					commandResult = "User <@" + strings.ToUpper(user) + "> configured as admin for this channel :white_check_mark:"
-				}
+			previousRole := ""
+			if currentRole == RoleNone {
+				cmd.db.CreateAdmin(channel, user, role.String())
 			} else {
-				log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
-				commandResult = "User <@" + strings.ToUpper(who) + "> has no permissions to configure admins for this channel :no_entry_sign:"
+				previousRole = currentRole.String()
+				cmd.db.UpdateAdminRole(channel, user, role.String())
 			}
+			cmd.audit(channel, who, "set admin", parameters, previousRole, role.String())
+			log.Printf("User %s configured %s for channel %s by user %s", user, role, channel, who)
+			commandResult = reply(cmd.t(channel, "admin.set.done", "user", "<@"+strings.ToUpper(user)+">", "role", role.String()))
 		}
 	} else {
-		log.Printf("No user detected, received %s as user", user)
-		commandResult = "No user detected. Usage kb set admin @user :warning:"
+		log.Printf("Requester user %s, has no permissions to configure %s on channel %s. Operation canceled", who, role, channel)
+		commandResult = failure(cmd.t(channel, "admin.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">", "role", role.String()))
 	}
 	return commandResult
 }
@@ -449,22 +878,21 @@ func (cmd *Commands) getAdmins(channel string) (admins []string, commandResult s
 	log.Printf("Getting admins for channel %s", channel)
 	admins = cmd.db.GetAdmins(channel)
 	if len(admins) > 0 {
-		commandResult = "Admins configured in this channel:\n"
+		commandResult = cmd.t(channel, "admin.get.title")
 		for _, a := range admins {
-			commandResult += "* <@" + strings.ToUpper(a) + ">\n"
+			commandResult += "* <@" + strings.ToUpper(a) + "> (" + cmd.db.GetRole(channel, a) + ")\n"
 		}
 	} else {
-		commandResult = "No admins configured for this channel yet"
+		commandResult = cmd.t(channel, "admin.get.none")
 	}
-	return admins, commandResult
-}
-
-// helper function that returns true if a string exists in a slice
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
+	if len(cmd.superAdmins.Users) > 0 {
+		commandResult += cmd.t(channel, "admin.get.super_admins")
+		for _, a := range cmd.superAdmins.Users {
+			commandResult += "* <@" + strings.ToUpper(a) + ">\n"
 		}
 	}
-	return false
+	if cmd.superAdmins.SyncFromSlack {
+		commandResult += cmd.t(channel, "admin.get.slack_admins")
+	}
+	return admins, commandResult
 }
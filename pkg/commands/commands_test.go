@@ -0,0 +1,389 @@
+package commands
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/mvazquezc/karma-bot/pkg/settings"
+)
+
+// newTestCommands returns commands backed by an empty database in a temporary directory
+func newTestCommands(t *testing.T, superAdmins SuperAdmins) (Commands, *database.Database) {
+	t.Helper()
+	db := database.New(filepath.Join(t.TempDir(), "karma.db"))
+	db.Connect()
+	return New(&db, nil, superAdmins), &db
+}
+
+// message returns an English message of the catalog as shown when invoked with the kb prefix
+func message(key string, args ...string) string {
+	return strings.ReplaceAll(i18n.T("en", key, args...), "{prefix}", "kb")
+}
+
+// daysAgo returns the timestamp of noon a number of days ago, karma days are local dates so noon keeps them
+// apart regardless of the time tests run
+func daysAgo(days int) int64 {
+	year, month, day := time.Now().Date()
+	return time.Date(year, month, day, 12, 0, 0, 0, time.Local).AddDate(0, 0, -days).Unix()
+}
+
+func TestKarmaStreak(t *testing.T) {
+	now := time.Date(2021, time.March, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		now  time.Time
+		days []string
+		want int
+	}{
+		{name: "no karma"},
+		{name: "today", days: []string{"2021-03-10"}, want: 1},
+		{name: "yesterday keeps the streak", days: []string{"2021-03-09", "2021-03-08"}, want: 2},
+		{name: "two days ago breaks the streak", days: []string{"2021-03-08", "2021-03-07"}},
+		{name: "consecutive days", days: []string{"2021-03-10", "2021-03-09", "2021-03-08"}, want: 3},
+		{name: "gap", days: []string{"2021-03-10", "2021-03-09", "2021-03-07"}, want: 2},
+		{name: "month boundary", now: time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC), days: []string{"2021-03-01", "2021-02-28", "2021-02-27"}, want: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testNow := test.now
+			if testNow.IsZero() {
+				testNow = now
+			}
+			if got := karmaStreak(test.days, testNow); got != test.want {
+				t.Errorf("karmaStreak(%v) = %d, want %d", test.days, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDelAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		who      string
+		user     string
+		want     string
+		wantRole string
+	}{
+		{name: "invalid user ID", who: "u9", user: "<@u3'>", want: message("admin.del.usage")},
+		{name: "no user", who: "u1", user: "u3", want: message("admin.del.usage"), wantRole: "moderator"},
+		{name: "not an admin requester", who: "u9", user: "<@u3>", want: message("admin.del.no_permissions", "user", "<@U9>", "role", "moderator"), wantRole: "moderator"},
+		{name: "moderator requester", who: "u3", user: "<@u3>", want: message("admin.del.no_permissions", "user", "<@U3>", "role", "moderator"), wantRole: "moderator"},
+		{name: "admin deletes owner", who: "u2", user: "<@u1>", want: message("admin.del.no_permissions", "user", "<@U2>", "role", "owner"), wantRole: "owner"},
+		{name: "not an admin", who: "u1", user: "<@u9>", want: message("admin.del.not_admin", "user", "<@U9>")},
+		{name: "admin deletes moderator", who: "u2", user: "<@u3>", want: message("admin.del.done", "user", "<@U3>")},
+		{name: "owner deletes admin", who: "u1", user: "<@u2>", want: message("admin.del.done", "user", "<@U2>")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, db := newTestCommands(t, SuperAdmins{})
+			db.CreateAdmin("general", "u1", "owner")
+			db.CreateAdmin("general", "u2", "admin")
+			db.CreateAdmin("general", "u3", "moderator")
+			response := cmd.ProcessCommand("general", test.who, "kb", "del", "admin", test.user)
+			if response.Text != test.want {
+				t.Errorf("del admin %s by %s = %q, want %q", test.user, test.who, response.Text, test.want)
+			}
+			user := strings.TrimSuffix(strings.TrimPrefix(test.user, "<@"), ">")
+			if role := db.GetRole("general", user); role != test.wantRole {
+				t.Errorf("role of %s = %q, want %q", user, role, test.wantRole)
+			}
+		})
+	}
+}
+
+func TestSetAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		who      string
+		args     string
+		want     string
+		wantRole string
+	}{
+		{name: "invalid user ID", who: "u1", args: "<@u3'> admin", want: message("admin.set.usage")},
+		{name: "owner sets admin", who: "u1", args: "<@u3>", want: message("admin.set.done", "user", "<@U3>", "role", "admin"), wantRole: "admin"},
+		{name: "admin sets moderator", who: "u2", args: "<@u3> moderator", want: message("admin.set.done", "user", "<@U3>", "role", "moderator"), wantRole: "moderator"},
+		{name: "admin sets admin", who: "u2", args: "<@u3> admin", want: message("admin.set.no_permissions", "user", "<@U2>", "role", "admin")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, db := newTestCommands(t, SuperAdmins{})
+			db.CreateAdmin("general", "u1", "owner")
+			db.CreateAdmin("general", "u2", "admin")
+			response := cmd.ProcessCommand("general", test.who, "kb", "set", "admin", test.args)
+			if response.Text != test.want {
+				t.Errorf("set admin %s by %s = %q, want %q", test.args, test.who, response.Text, test.want)
+			}
+			if role := db.GetRole("general", "u3"); role != test.wantRole {
+				t.Errorf("role of u3 = %q, want %q", role, test.wantRole)
+			}
+		})
+	}
+}
+
+func TestDelSetting(t *testing.T) {
+	tests := []struct {
+		name      string
+		who       string
+		args      string
+		want      string
+		wantValue string
+	}{
+		{name: "unknown setting", who: "u1", args: "foo'", want: message("setting.invalid_name", "setting", "foo'"), wantValue: "3"},
+		{name: "not configured", who: "u1", args: "notify_karma", want: message("setting.del.not_configured", "setting", "notify_karma", "scope", message("scope.channel")), wantValue: "3"},
+		{name: "no permissions", who: "u9", args: "karma_max_delta", want: message("setting.del.no_permissions", "user", "<@U9>", "scope", message("scope.channel")), wantValue: "3"},
+		{name: "falls back to workspace", who: "u1", args: "karma_max_delta", want: message("setting.del.done", "user", "<@U1>", "setting", "karma_max_delta", "scope", message("scope.channel"), "value", "7", "source", message("source.workspace")), wantValue: "7"},
+		{name: "workspace needs super-admin", who: "u1", args: "workspace karma_max_delta", want: message("setting.del.no_permissions", "user", "<@U1>", "scope", message("scope.workspace")), wantValue: "3"},
+		{name: "workspace", who: "u0", args: "workspace karma_max_delta", want: message("setting.del.done", "user", "<@U0>", "setting", "karma_max_delta", "scope", message("scope.workspace"), "value", "3", "source", message("source.channel")), wantValue: "3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, db := newTestCommands(t, SuperAdmins{Users: []string{"u0"}})
+			db.CreateAdmin("general", "u1", "owner")
+			db.SetSetting(database.WorkspaceScope, "karma_max_delta", "7")
+			db.SetSetting("general", "karma_max_delta", "3")
+			response := cmd.ProcessCommand("general", test.who, "kb", "del", "setting", test.args)
+			if response.Text != test.want {
+				t.Errorf("del setting %s by %s = %q, want %q", test.args, test.who, response.Text, test.want)
+			}
+			if value := db.GetSettingValue("general", "karma_max_delta"); value != test.wantValue {
+				t.Errorf("karma_max_delta = %q, want %q", value, test.wantValue)
+			}
+		})
+	}
+}
+
+func TestSettingMessagesAreTranslated(t *testing.T) {
+	cmd, db := newTestCommands(t, SuperAdmins{})
+	db.CreateAdmin("general", "u1", "owner")
+	db.SetSetting("general", "language", "es")
+	tests := []struct {
+		operation string
+		args      string
+		want      string
+	}{
+		{
+			operation: "set",
+			args:      "notify_karma 0",
+			want:      i18n.T("es", "setting.set.invalid_value", "value", "0", "setting", "notify_karma", "error", "se esperaba un valor entre 1 y 1000"),
+		},
+		{
+			operation: "get",
+			args:      "notify_karma",
+			want:      i18n.T("es", "setting.get.value", "setting", "notify_karma", "value", "1", "source", "por defecto", "usage", "entero entre 1 y 1000", "help", i18n.T("es", "setting.notify_karma")),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.operation, func(t *testing.T) {
+			if response := cmd.ProcessCommand("general", "u1", "kb", test.operation, "setting", test.args); response.Text != test.want {
+				t.Errorf("%s setting %s = %q, want %q", test.operation, test.args, response.Text, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRankArgs(t *testing.T) {
+	tests := []struct {
+		args         string
+		wantPeriod   string
+		wantPage     int
+		wantPageSize int
+		wantBottom   bool
+		wantValid    bool
+	}{
+		{args: "", wantPeriod: "all", wantPage: 1, wantPageSize: rankPageSize, wantValid: true},
+		{args: "all", wantPeriod: "all", wantPage: 1, wantPageSize: 0, wantValid: true},
+		{args: "bottom", wantPeriod: "all", wantPage: 1, wantPageSize: rankPageSize, wantBottom: true, wantValid: true},
+		{args: "page 3", wantPeriod: "all", wantPage: 3, wantPageSize: rankPageSize, wantValid: true},
+		{args: "week page 2", wantPeriod: "week", wantPage: 2, wantPageSize: rankPageSize, wantValid: true},
+		{args: "all all", wantPeriod: "all", wantPage: 1, wantPageSize: 0, wantValid: true},
+		{args: "page 0", wantPeriod: "all", wantPage: 1, wantPageSize: rankPageSize},
+		{args: "page two", wantPeriod: "all", wantPage: 1, wantPageSize: rankPageSize},
+		{args: "top", wantPeriod: "all", wantPage: 1, wantPageSize: rankPageSize},
+	}
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			period, page, pageSize, bottom, valid := parseRankArgs(test.args)
+			if period != test.wantPeriod || page != test.wantPage || pageSize != test.wantPageSize || bottom != test.wantBottom || valid != test.wantValid {
+				t.Errorf("parseRankArgs(%q) = (%q, %d, %d, %t, %t), want (%q, %d, %d, %t, %t)", test.args, period, page, pageSize, bottom, valid,
+					test.wantPeriod, test.wantPage, test.wantPageSize, test.wantBottom, test.wantValid)
+			}
+		})
+	}
+}
+
+func TestGetKarmaRankPages(t *testing.T) {
+	cmd, db := newTestCommands(t, SuperAdmins{})
+	now := time.Now().Unix()
+	for i := 0; i < 12; i++ {
+		db.UpdateKarma("general", "word"+strconv.Itoa(10+i), 20-i, "u1", now)
+	}
+	// Ties share their position, the next word keeps its place in the rank
+	db.UpdateKarma("general", "word09", 20, "u1", now)
+	tests := []struct {
+		args        string
+		wantWords   []string
+		unwanted    []string
+		wantVisible Visibility
+	}{
+		{args: "", wantWords: []string{"1. word09", "1. word10", "3. word11", "10. word18"}, unwanted: []string{"word19"}, wantVisible: VisibilityPublic},
+		{args: "page 2", wantWords: []string{"11. word19", "13. word21"}, unwanted: []string{"word18"}, wantVisible: VisibilityPublic},
+		{args: "bottom", wantWords: []string{"4. word12", "13. word21"}, unwanted: []string{"word11"}, wantVisible: VisibilityPublic},
+		{args: "all", wantWords: []string{"1. word09", "13. word21"}, wantVisible: VisibilityDM},
+	}
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			response := cmd.ProcessCommand("general", "u1", "kb", "rank", "karma", test.args)
+			for _, word := range test.wantWords {
+				if !strings.Contains(response.Text, word) {
+					t.Errorf("rank karma %s = %q, want it to contain %q", test.args, response.Text, word)
+				}
+			}
+			for _, word := range test.unwanted {
+				if strings.Contains(response.Text, word) {
+					t.Errorf("rank karma %s = %q, want it not to contain %q", test.args, response.Text, word)
+				}
+			}
+			if response.Visibility != test.wantVisible {
+				t.Errorf("rank karma %s visibility = %q, want %q", test.args, response.Visibility, test.wantVisible)
+			}
+		})
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	cmd, db := newTestCommands(t, SuperAdmins{})
+	now := time.Now().Unix()
+	// u1 receives karma on the last three days and gives karma to other words and users
+	db.UpdateKarma("general", "<@u1>", 6, "u2", now)
+	db.LogKarma("general", "<@u1>", "u2", 3, now)
+	db.LogKarma("general", "<@u1>", "u3", 2, daysAgo(1))
+	db.LogKarma("general", "<@u1>", "u2", 2, daysAgo(2))
+	db.LogKarma("general", "<@u1>", "u3", -1, daysAgo(2))
+	db.UpdateKarma("general", "foo", 10, "u1", now)
+	db.LogKarma("general", "foo", "u1", 4, now)
+	db.LogKarma("general", "<@u2>", "u1", 1, now)
+	db.LogKarma("general", "bar", "u1", -2, now)
+	db.UpdateKarma("random", "<@u1>", 4, "u2", now)
+	// u4 karma is kept under an alias
+	db.SetAlias("<@u4>", "dave", "general")
+	db.UpdateKarma("general", "dave", 2, "u1", now)
+	db.LogKarma("general", "dave", "u1", 2, daysAgo(5))
+
+	tests := []struct {
+		user string
+		want string
+	}{
+		{user: "<@u1>", want: message("profile.title", "user", "<@U1>", "word", "<@u1>") +
+			message("profile.karma", "karma", "6", "global", "10") +
+			message("profile.rank", "position", "#2", "total", "3") +
+			message("profile.given", "positive", "7", "negative", "2") +
+			message("profile.received", "positive", "7", "negative", "1") +
+			message("profile.boosted_words", "words", "`foo (4)`, `dave (2)`, `<@u2> (1)`") +
+			message("profile.boosters", "users", "<@U2> (5), <@U3> (2)") +
+			message("profile.streak", "days", "3") + " :fire:\n"},
+		{user: "<@u4>", want: message("profile.title", "user", "<@U4>", "word", "dave") +
+			message("profile.karma", "karma", "2", "global", "2") +
+			message("profile.rank", "position", "#3", "total", "3") +
+			message("profile.given", "positive", "0", "negative", "0") +
+			message("profile.received", "positive", "2", "negative", "0") +
+			message("profile.boosters", "users", "<@U1> (2)") +
+			message("profile.streak", "days", "0") + "\n"},
+		{user: "<@u1'>", want: message("profile.usage")},
+		{user: "u1", want: message("profile.usage")},
+	}
+	for _, test := range tests {
+		t.Run(test.user, func(t *testing.T) {
+			if response := cmd.ProcessCommand("general", "u9", "kb", "get", "profile", test.user); response.Text != test.want {
+				t.Errorf("get profile %s = %q, want %q", test.user, response.Text, test.want)
+			}
+		})
+	}
+}
+
+func TestKarmaBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		who       string
+		args      string
+		want      string
+		wantUsed  string
+		wantAudit bool
+	}{
+		{name: "no permissions", who: "u9", args: "<@u2>", want: message("budget.del.no_permissions", "user", "<@U9>"), wantUsed: "`3/5`"},
+		{name: "no user", who: "u1", args: "u2", want: message("budget.del.usage"), wantUsed: "`3/5`"},
+		{name: "invalid user ID", who: "u1", args: "<@u2'>", want: message("budget.del.usage"), wantUsed: "`3/5`"},
+		{name: "reset", who: "u1", args: "<@u2>", want: message("budget.del.done", "user", "<@U1>", "target", "<@U2>"), wantUsed: "`0/5`", wantAudit: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, db := newTestCommands(t, SuperAdmins{})
+			db.CreateAdmin("general", "u1", "moderator")
+			db.SetSetting("general", "karma_budget_positive", "5")
+			// The budget is reset from the next second, karma given before it no longer uses the budget
+			db.LogKarma("general", "foo", "u2", 3, time.Now().Unix()-1)
+			if response := cmd.ProcessCommand("general", test.who, "kb", "del", "budget", test.args); response.Text != test.want {
+				t.Errorf("del budget %s by %s = %q, want %q", test.args, test.who, response.Text, test.want)
+			}
+			budget := message("budget.usage", "period", message("budget.period.today"), "positive", test.wantUsed, "negative", message("budget.unlimited", "used", "0"))
+			if response, want := cmd.ProcessCommand("general", "u2", "kb", "get", "budget", ""), message("budget.get", "user", "<@U2>", "budget", budget); response.Text != want {
+				t.Errorf("get budget = %q, want %q", response.Text, want)
+			}
+			if audited := len(db.GetAuditLog("general", 10)) > 0; audited != test.wantAudit {
+				t.Errorf("budget reset audited = %t, want %t", audited, test.wantAudit)
+			}
+		})
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	tests := []struct {
+		name      string
+		who       string
+		args      string
+		want      string
+		setting   string
+		wantValue string
+	}{
+		{name: "int", who: "u1", args: "notify_karma 5", want: message("setting.set.done", "user", "<@U1>", "setting", "notify_karma", "value", "5", "scope", message("scope.channel")), setting: "notify_karma", wantValue: "5"},
+		{name: "normalized bool", who: "u1", args: "use_karma_emojis YES", want: message("setting.set.done", "user", "<@U1>", "setting", "use_karma_emojis", "value", "true", "scope", message("scope.channel")), setting: "use_karma_emojis", wantValue: "true"},
+		{name: "normalized duration", who: "u1", args: "Karma_Cooldown 90S", want: message("setting.set.done", "user", "<@U1>", "setting", "karma_cooldown", "value", "1m30s", "scope", message("scope.channel")), setting: "karma_cooldown", wantValue: "1m30s"},
+		{name: "space separated list", who: "u1", args: "karma_blocklist c++ g++", want: message("setting.set.done", "user", "<@U1>", "setting", "karma_blocklist", "value", "c++,g++", "scope", message("scope.channel")), setting: "karma_blocklist", wantValue: "c++,g++"},
+		{name: "invalid value", who: "u1", args: "notify_karma 0", want: message("setting.set.invalid_value", "value", "0", "setting", "notify_karma", "error", message("validation.int_range", "min", "1", "max", "1000")), setting: "notify_karma", wantValue: "1"},
+		{name: "unknown setting", who: "u1", args: "foo 1", want: message("setting.invalid_name", "setting", "foo"), setting: "notify_karma", wantValue: "1"},
+		{name: "missing value", who: "u1", args: "notify_karma", want: message("setting.set.usage"), setting: "notify_karma", wantValue: "1"},
+		{name: "no permissions", who: "u9", args: "notify_karma 5", want: message("setting.set.no_permissions", "user", "<@U9>", "scope", message("scope.channel")), setting: "notify_karma", wantValue: "1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, db := newTestCommands(t, SuperAdmins{})
+			db.CreateAdmin("general", "u1", "admin")
+			if response := cmd.ProcessCommand("general", test.who, "kb", "set", "setting", test.args); response.Text != test.want {
+				t.Errorf("set setting %s by %s = %q, want %q", test.args, test.who, response.Text, test.want)
+			}
+			if value := db.GetSettingValue("general", test.setting); value != test.wantValue {
+				t.Errorf("%s = %q, want %q", test.setting, value, test.wantValue)
+			}
+		})
+	}
+}
+
+func TestGetSettingListsEverySetting(t *testing.T) {
+	cmd, db := newTestCommands(t, SuperAdmins{})
+	db.SetSetting("general", "notify_karma", "5")
+	response := cmd.ProcessCommand("general", "u1", "kb", "get", "setting", "")
+	for _, setting := range settings.All() {
+		value, source := db.GetEffectiveSetting("general", setting.Name)
+		want := message("setting.get.value", "setting", setting.Name, "value", value, "source", message("source."+source), "usage", setting.Usage("en"), "help", message("setting."+setting.Name))
+		if !strings.Contains(response.Text, want) {
+			t.Errorf("get setting = %q, want it to contain %q", response.Text, want)
+		}
+	}
+	if !strings.Contains(response.Text, "`notify_karma` is `5` (channel)") {
+		t.Errorf("get setting = %q, want notify_karma to be 5 from the channel", response.Text)
+	}
+}
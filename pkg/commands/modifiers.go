@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+	"github.com/mvazquezc/karma-bot/pkg/utils"
+)
+
+// maxModifierKarma maximum karma a custom modifier can be configured to give, it is bounded later by karma_max_delta
+const maxModifierKarma = 1000
+
+// usage: kb set modifier modifier karma
+func (cmd *Commands) setModifier(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
+		params := strings.Fields(parameters)
+		if len(params) != 2 {
+			log.Printf("Received incorrect parameters. Params: %s", parameters)
+			return failure(cmd.t(channel, "modifier.set.usage"))
+		}
+		modifier := params[0]
+		karma, err := strconv.Atoi(params[1])
+		if err != nil || karma < -maxModifierKarma || karma > maxModifierKarma {
+			log.Printf("Received incorrect karma %s for modifier %s", params[1], modifier)
+			commandResult = failure(cmd.t(channel, "modifier.invalid_karma", "karma", params[1], "max", strconv.Itoa(maxModifierKarma)))
+		} else if err := parser.ValidateModifier(modifier); err != nil {
+			log.Printf("Received incorrect modifier %s: %s", modifier, err)
+			commandResult = failure(cmd.t(channel, "modifier.invalid", "modifier", modifier, "error", cmd.errorMessage(channel, err)))
+		} else {
+			previousKarma, configured := cmd.db.GetModifiers(channel)[modifier]
+			previousValue := ""
+			if configured {
+				previousValue = strconv.Itoa(previousKarma)
+			}
+			cmd.db.SetModifier(channel, modifier, karma)
+			cmd.audit(channel, who, "set modifier", parameters, previousValue, strconv.Itoa(karma))
+			log.Printf("Modifier %s configured to %d on channel %s", modifier, karma, channel)
+			commandResult = reply(cmd.t(channel, "modifier.set.done", "user", "<@"+strings.ToUpper(who)+">", "modifier", modifier, "karma", strconv.Itoa(karma)))
+		}
+	} else {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		commandResult = failure(cmd.t(channel, "modifier.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
+	}
+	return commandResult
+}
+
+// usage: kb del modifier modifier
+func (cmd *Commands) delModifier(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
+		params := strings.Fields(parameters)
+		if len(params) != 1 {
+			log.Printf("Received incorrect parameters. Params: %s", parameters)
+			return failure(cmd.t(channel, "modifier.del.usage"))
+		}
+		modifier := params[0]
+		previousKarma, configured := cmd.db.GetModifiers(channel)[modifier]
+		if !configured {
+			log.Printf("Modifier %s is not configured on channel %s", modifier, channel)
+			commandResult = failure(cmd.t(channel, "modifier.del.not_configured", "modifier", modifier))
+		} else {
+			cmd.db.DelModifier(channel, modifier)
+			cmd.audit(channel, who, "del modifier", parameters, strconv.Itoa(previousKarma), "")
+			log.Printf("Modifier %s deleted from channel %s", modifier, channel)
+			commandResult = reply(cmd.t(channel, "modifier.del.done", "user", "<@"+strings.ToUpper(who)+">", "modifier", modifier))
+		}
+	} else {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		commandResult = failure(cmd.t(channel, "modifier.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
+	}
+	return commandResult
+}
+
+// getModifiers lists the modifiers available on a channel and the karma they give
+// usage: kb get modifier
+func (cmd *Commands) getModifiers(channel string) Response {
+	channelModifiers := cmd.db.GetModifiers(channel)
+	modifiers := utils.GetKarmaModifiers(*cmd.db, channel)
+	var names []string
+	for modifier := range modifiers {
+		names = append(names, modifier)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if modifiers[names[i]] != modifiers[names[j]] {
+			return modifiers[names[i]] > modifiers[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	commandResult := cmd.t(channel, "modifier.get.title", "max", cmd.db.GetSettingValue(channel, "karma_max_delta"))
+	for _, modifier := range names {
+		source := database.SourceDefault
+		if _, configured := channelModifiers[modifier]; configured {
+			source = database.SourceChannel
+		}
+		commandResult += cmd.t(channel, "modifier.get.entry", "modifier", modifier, "karma", strconv.Itoa(modifiers[modifier]), "source", cmd.sourceName(channel, source))
+	}
+	return reply(commandResult)
+}
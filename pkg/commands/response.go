@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Visibility of a command response
+type Visibility string
+
+const (
+	// VisibilityPublic response posted to the channel
+	VisibilityPublic Visibility = "public"
+	// VisibilityEphemeral response only shown to the requester in the channel
+	VisibilityEphemeral Visibility = "ephemeral"
+	// VisibilityDM response sent to the requester in a DM
+	VisibilityDM Visibility = "dm"
+)
+
+// Response output of a command and who should see it
+type Response struct {
+	// Text plain text output, used as fallback when the response has blocks
+	Text string
+	// Blocks Block Kit rendering of the output, only set when the channel uses Block Kit
+	Blocks []slack.Block
+	// Visibility who sees the response, commands leave it empty to use the command_response setting of the channel
+	Visibility Visibility
+}
+
+// reply returns the response for a command output, it follows the command_response setting of the channel
+func reply(text string) Response {
+	return Response{Text: text}
+}
+
+// failure returns the response for errors and permission denied messages, only shown to the requester
+func failure(text string) Response {
+	return Response{Text: text, Visibility: VisibilityEphemeral}
+}
+
+// newResponse completes the response of a command, responses without a visibility declared by the command
+// use the command_response setting of the channel, and the {prefix} placeholder of usage messages is replaced
+// with the command prefix
+func (cmd *Commands) newResponse(channel string, commandPrefix string, response Response) Response {
+	if len(response.Visibility) == 0 {
+		response.Visibility = Visibility(cmd.db.GetSettingValue(channel, "command_response"))
+	}
+	if len(commandPrefix) == 0 {
+		commandPrefix = cmd.db.GetSettingValue(channel, "command_prefix")
+	}
+	response.Text = strings.ReplaceAll(response.Text, "{prefix}", commandPrefix)
+	return response
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"log"
+	"strings"
+)
+
+// Role of a user in a channel, higher roles have the permissions of the lower ones
+type Role int
+
+const (
+	// RoleNone regular channel user
+	RoleNone Role = iota
+	// RoleModerator can manage aliases and karma budgets
+	RoleModerator
+	// RoleAdmin can also manage karma, settings and moderators
+	RoleAdmin
+	// RoleOwner can also manage admins and owners
+	RoleOwner
+	// RoleSuperAdmin workspace level admin, owner of every channel
+	RoleSuperAdmin
+)
+
+// roleNames maps the role names used in the database and commands to roles
+var roleNames = map[string]Role{
+	"moderator": RoleModerator,
+	"admin":     RoleAdmin,
+	"owner":     RoleOwner,
+}
+
+// String returns the role name
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	if r == RoleSuperAdmin {
+		return "super-admin"
+	}
+	return "user"
+}
+
+// requiredRoleToManage returns the role needed to grant or revoke a role
+func requiredRoleToManage(role Role) Role {
+	if role <= RoleModerator {
+		return RoleAdmin
+	}
+	return RoleOwner
+}
+
+// SuperAdmins workspace level admins that can act on every channel
+type SuperAdmins struct {
+	// Users IDs of the users configured as super-admins at startup
+	Users []string
+	// SyncFromSlack makes Slack workspace admins and owners super-admins
+	SyncFromSlack bool
+}
+
+// configured returns true if the workspace has super-admins
+func (s SuperAdmins) configured() bool {
+	return len(s.Users) > 0 || s.SyncFromSlack
+}
+
+// isSuperAdmin returns true if the user is a workspace super-admin
+func (cmd *Commands) isSuperAdmin(user string) bool {
+	for _, superAdmin := range cmd.superAdmins.Users {
+		if strings.EqualFold(superAdmin, user) {
+			return true
+		}
+	}
+	if cmd.superAdmins.SyncFromSlack {
+		userInfo, err := cmd.api.GetUserInfo(strings.ToUpper(user))
+		if err != nil {
+			log.Printf("Cannot get user information for user %s: %s", user, err)
+			return false
+		}
+		return userInfo.IsAdmin || userInfo.IsOwner || userInfo.IsPrimaryOwner
+	}
+	return false
+}
+
+// getRole returns the role of a user in a channel
+func (cmd *Commands) getRole(channel string, user string) Role {
+	if cmd.isSuperAdmin(user) {
+		return RoleSuperAdmin
+	}
+	return roleNames[cmd.db.GetRole(channel, user)]
+}
+
+// hasRole returns true if the user has at least the given role in a channel
+func (cmd *Commands) hasRole(channel string, user string, role Role) bool {
+	return cmd.getRole(channel, user) >= role
+}
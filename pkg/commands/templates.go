@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+	"github.com/mvazquezc/karma-bot/pkg/templates"
+)
+
+// sourcePreview source of a template previewed without configuring it
+const sourcePreview = "preview"
+
+// usage: kb set template template_name template
+func (cmd *Commands) setTemplate(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
+		templateName, templateText := splitTemplateArgs(parameters)
+		validTemplate := templates.Exists(templateName)
+		if len(templateText) <= 0 {
+			log.Printf("Received incorrect parameters. Params: %s", parameters)
+			commandResult = failure(cmd.t(channel, "template.set.usage"))
+		} else if !validTemplate {
+			log.Printf("Received incorrect template %s", templateName)
+			commandResult = failure(cmd.t(channel, "template.invalid_name", "template", templateName))
+		} else {
+			preview, err := templates.Validate(templateText)
+			if err != nil {
+				log.Printf("Received incorrect template %s for %s: %s", templateText, templateName, err)
+				commandResult = failure(cmd.t(channel, "template.invalid", "template", templateName, "error", cmd.errorMessage(channel, err), "variables", templateVariables()))
+			} else {
+				previousTemplate := cmd.db.GetTemplate(channel, templateName)
+				cmd.db.SetTemplate(channel, templateName, templateText)
+				cmd.audit(channel, who, "set template", parameters, previousTemplate, templateText)
+				log.Printf("Template %s configured to %s on channel %s", templateName, templateText, channel)
+				commandResult = reply(cmd.t(channel, "template.set.done", "user", "<@"+strings.ToUpper(who)+">", "template", templateName, "preview", preview))
+			}
+		}
+	} else {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		commandResult = failure(cmd.t(channel, "template.set.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
+	}
+	return commandResult
+}
+
+// usage: kb del template template_name
+func (cmd *Commands) delTemplate(channel string, parameters string, who string) Response {
+	var commandResult Response
+	requesterHasRole := cmd.hasRole(channel, who, RoleAdmin)
+	if requesterHasRole {
+		params := strings.Fields(parameters)
+		if len(params) != 1 {
+			log.Printf("Received incorrect parameters. Params: %s", parameters)
+			commandResult = failure(cmd.t(channel, "template.del.usage"))
+		} else {
+			templateName := params[0]
+			previousTemplate := cmd.db.GetTemplate(channel, templateName)
+			if len(previousTemplate) <= 0 {
+				log.Printf("Template %s is not configured on channel %s", templateName, channel)
+				commandResult = failure(cmd.t(channel, "template.del.not_configured", "template", templateName))
+			} else {
+				cmd.db.DelTemplate(channel, templateName)
+				cmd.audit(channel, who, "del template", parameters, previousTemplate, "")
+				log.Printf("Template %s deleted from channel %s", templateName, channel)
+				commandResult = reply(cmd.t(channel, "template.del.done", "user", "<@"+strings.ToUpper(who)+">", "template", templateName))
+			}
+		}
+	} else {
+		log.Printf("Requester user %s, is not admin on channel %s. Operation canceled", who, channel)
+		commandResult = failure(cmd.t(channel, "template.del.no_permissions", "user", "<@"+strings.ToUpper(who)+">"))
+	}
+	return commandResult
+}
+
+// getTemplate lists the templates, shows a template with a preview, or previews a template without configuring it
+// usage: kb get template [template_name] | kb get template preview template_name [template]
+func (cmd *Commands) getTemplate(channel string, parameters string, who string) Response {
+	templateName, templateText := splitTemplateArgs(parameters)
+	if len(templateName) <= 0 {
+		commandResult := cmd.t(channel, "template.get.title", "variables", templateVariables())
+		for _, name := range templates.Names() {
+			source := database.SourceDefault
+			if len(cmd.db.GetTemplate(channel, name)) > 0 {
+				source = database.SourceChannel
+			}
+			commandResult += cmd.t(channel, "template.get.entry", "template", name, "source", cmd.sourceName(channel, source), "help", templates.Help(cmd.language(channel), name))
+		}
+		return reply(commandResult)
+	}
+	preview := templateName == "preview"
+	if preview {
+		templateName, templateText = splitTemplateArgs(templateText)
+	} else if len(templateText) > 0 {
+		return failure(cmd.t(channel, "template.get.usage"))
+	}
+	if !templates.Exists(templateName) {
+		log.Printf("Template %s does not exist", templateName)
+		return failure(cmd.t(channel, "template.invalid_name", "template", templateName))
+	}
+	source := sourcePreview
+	if len(templateText) <= 0 {
+		source = database.SourceChannel
+		templateText = cmd.db.GetTemplate(channel, templateName)
+		if len(templateText) <= 0 {
+			source = database.SourceDefault
+			templateText = templates.Default(cmd.language(channel), templateName)
+		}
+	}
+	rendered, err := templates.Render(templateText, templates.SampleData("<@"+strings.ToUpper(who)+">"))
+	if err != nil {
+		log.Printf("Cannot render template %s: %s", templateText, err)
+		return failure(cmd.t(channel, "template.invalid", "template", templateName, "error", cmd.errorMessage(channel, err), "variables", templateVariables()))
+	}
+	return reply(cmd.t(channel, "template.get.value", "template", templateName, "source", cmd.sourceName(channel, source), "help", templates.Help(cmd.language(channel), templateName), "text", templateText, "preview", rendered))
+}
+
+// splitTemplateArgs returns the template name and the rest of the parameters, the template text keeps its spacing and case
+func splitTemplateArgs(parameters string) (templateName string, templateText string) {
+	parameters = strings.TrimSpace(parameters)
+	params := strings.Fields(parameters)
+	if len(params) == 0 {
+		return "", ""
+	}
+	templateName = parser.Normalize(params[0])
+	return templateName, templates.Normalize(strings.TrimPrefix(parameters, params[0]))
+}
+
+// templateVariables returns the variables available in templates formatted for messages
+func templateVariables() string {
+	return "`{{." + strings.Join(templates.Variables, "}}`, `{{.") + "}}`"
+}
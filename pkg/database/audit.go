@@ -0,0 +1,37 @@
+package database
+
+// AuditEntry an admin operation recorded in the audit log
+type AuditEntry struct {
+	Channel   string
+	Actor     string
+	Command   string
+	Arguments string
+	Before    string
+	After     string
+	Timestamp int64
+}
+
+// AddAuditEntry persists an admin operation in the audit log
+func (db *Database) AddAuditEntry(entry AuditEntry) {
+	// Arguments are free text typed by users, so values are passed as parameters instead of building the statement
+	auditInsert := "INSERT INTO audit_log(channel, actor, command, arguments, before, after, timestamp) values (?, ?, ?, ?, ?, ?, ?)"
+	db.runStatement(auditInsert, entry.Channel, entry.Actor, entry.Command, entry.Arguments, entry.Before, entry.After, entry.Timestamp)
+}
+
+// GetAuditLog returns the last entries of the audit log for a channel, newest first
+func (db *Database) GetAuditLog(channel string, limit int) []AuditEntry {
+	query := "SELECT channel, actor, command, arguments, before, after, timestamp FROM audit_log WHERE channel == ? ORDER BY timestamp DESC, rowid DESC LIMIT ?;"
+	rows := db.runQuery(query, channel, limit)
+	defer rows.Close()
+
+	var auditLog []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		err := rows.Scan(&entry.Channel, &entry.Actor, &entry.Command, &entry.Arguments, &entry.Before, &entry.After, &entry.Timestamp)
+		if err != nil {
+			panic(err)
+		}
+		auditLog = append(auditLog, entry)
+	}
+	return auditLog
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"time"
+)
+
+// KarmaBudget holds how much karma a user can give in a channel during the current budget period
+// A limit of 0 means the user can give unlimited karma
+type KarmaBudget struct {
+	PositiveLimit int
+	NegativeLimit int
+	PositiveUsed  int
+	NegativeUsed  int
+	// Days length of the budget period, periods start at midnight
+	Days int
+}
+
+// Allows returns true if the budget has room for the given karma
+func (b KarmaBudget) Allows(karma int) bool {
+	if karma > 0 && b.PositiveLimit > 0 {
+		return b.PositiveUsed+karma <= b.PositiveLimit
+	}
+	if karma < 0 && b.NegativeLimit > 0 {
+		return b.NegativeUsed-karma <= b.NegativeLimit
+	}
+	return true
+}
+
+// GetKarmaBudget returns the karma budget for a user in a channel based on the channel settings
+// and the karma the user gave since the budget period started (or since an admin reset the budget)
+func (db *Database) GetKarmaBudget(channel string, user string) KarmaBudget {
+	budget := KarmaBudget{
+		PositiveLimit: db.GetIntSetting(channel, "karma_budget_positive"),
+		NegativeLimit: db.GetIntSetting(channel, "karma_budget_negative"),
+		Days:          db.GetIntSetting(channel, "karma_budget_days"),
+	}
+	if budget.Days <= 0 {
+		budget.Days = 1
+	}
+	if budget.PositiveLimit <= 0 && budget.NegativeLimit <= 0 {
+		return budget
+	}
+	year, month, day := time.Now().Date()
+	periodStart := time.Date(year, month, day, 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-budget.Days).Unix()
+	lastReset := db.getBudgetReset(channel, user)
+	if lastReset > periodStart {
+		periodStart = lastReset
+	}
+	budget.PositiveUsed, budget.NegativeUsed = db.GetKarmaGiven(channel, user, periodStart)
+	return budget
+}
+
+// ResetKarmaBudget gives a user their whole karma budget back for the current period
+func (db *Database) ResetKarmaBudget(channel string, user string, timestamp int64) {
+	budgetReset := "INSERT INTO budget_resets(channel, user, timestamp) values (?, ?, ?)"
+	db.runStatement(budgetReset, channel, user, timestamp)
+}
+
+// getBudgetReset returns the last time an admin reset the budget for a user in a channel
+func (db *Database) getBudgetReset(channel string, user string) int64 {
+	query := "SELECT COALESCE(MAX(timestamp), 0) FROM budget_resets WHERE user == ? AND channel == ?;"
+	rows := db.runQuery(query, user, channel)
+	defer rows.Close()
+
+	var lastReset int64
+	for rows.Next() {
+		err := rows.Scan(&lastReset)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return lastReset
+}
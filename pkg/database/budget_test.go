@@ -0,0 +1,92 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKarmaBudgetAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget KarmaBudget
+		karma  int
+		want   bool
+	}{
+		{name: "unlimited positive", budget: KarmaBudget{PositiveUsed: 1000}, karma: 5, want: true},
+		{name: "unlimited negative", budget: KarmaBudget{PositiveLimit: 1, NegativeUsed: 1000}, karma: -5, want: true},
+		{name: "positive room", budget: KarmaBudget{PositiveLimit: 10, PositiveUsed: 5}, karma: 5, want: true},
+		{name: "positive exhausted", budget: KarmaBudget{PositiveLimit: 10, PositiveUsed: 6}, karma: 5, want: false},
+		{name: "negative room", budget: KarmaBudget{NegativeLimit: 3, NegativeUsed: 1}, karma: -2, want: true},
+		{name: "negative exhausted", budget: KarmaBudget{NegativeLimit: 3, NegativeUsed: 2}, karma: -2, want: false},
+		{name: "negative limit only applies to negative karma", budget: KarmaBudget{NegativeLimit: 1, NegativeUsed: 1}, karma: 3, want: true},
+		{name: "zero karma", budget: KarmaBudget{PositiveLimit: 1, PositiveUsed: 5, NegativeLimit: 1, NegativeUsed: 5}, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.budget.Allows(test.karma); got != test.want {
+				t.Errorf("%+v.Allows(%d) = %t, want %t", test.budget, test.karma, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetKarmaBudget(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name             string
+		days             string
+		reset            int64
+		wantPositiveUsed int
+		wantNegativeUsed int
+	}{
+		// u1 gave +2 and -1 now, +3 two seconds ago, +4 yesterday, +5 six days ago and +6 seven days ago
+		{name: "today", wantPositiveUsed: 5, wantNegativeUsed: 1},
+		{name: "two days", days: "2", wantPositiveUsed: 9, wantNegativeUsed: 1},
+		{name: "week", days: "7", wantPositiveUsed: 14, wantNegativeUsed: 1},
+		{name: "reset", reset: now - 1, wantPositiveUsed: 2, wantNegativeUsed: 1},
+		{name: "reset before the period", days: "2", reset: daysAgo(3), wantPositiveUsed: 9, wantNegativeUsed: 1},
+		{name: "reset within the period", days: "7", reset: daysAgo(3), wantPositiveUsed: 9, wantNegativeUsed: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			db.SetSetting("general", "karma_budget_positive", "20")
+			if len(test.days) > 0 {
+				db.SetSetting("general", "karma_budget_days", test.days)
+			}
+			db.LogKarma("general", "foo", "u1", 2, now)
+			db.LogKarma("general", "bar", "u1", -1, now)
+			db.LogKarma("general", "baz", "u1", 3, now-2)
+			db.LogKarma("general", "foo", "u1", 4, daysAgo(1))
+			db.LogKarma("general", "foo", "u1", 5, daysAgo(6))
+			db.LogKarma("general", "foo", "u1", 6, daysAgo(7))
+			// Karma given by other users and in other channels does not use the budget
+			db.LogKarma("general", "foo", "u2", 7, now)
+			db.LogKarma("random", "foo", "u1", 8, now)
+			if test.reset > 0 {
+				db.ResetKarmaBudget("general", "u1", test.reset)
+				// Resets of other users do not change the budget
+				db.ResetKarmaBudget("general", "u2", now)
+			}
+			budget := db.GetKarmaBudget("general", "u1")
+			if budget.PositiveUsed != test.wantPositiveUsed || budget.NegativeUsed != test.wantNegativeUsed {
+				t.Errorf("GetKarmaBudget used = (%d, %d), want (%d, %d)", budget.PositiveUsed, budget.NegativeUsed, test.wantPositiveUsed, test.wantNegativeUsed)
+			}
+			if budget.PositiveLimit != 20 || budget.NegativeLimit != 0 {
+				t.Errorf("GetKarmaBudget limits = (%d, %d), want (20, 0)", budget.PositiveLimit, budget.NegativeLimit)
+			}
+		})
+	}
+}
+
+func TestGetKarmaBudgetUnlimited(t *testing.T) {
+	db := newTestDatabase(t)
+	db.LogKarma("general", "foo", "u1", 2, time.Now().Unix())
+	budget := db.GetKarmaBudget("general", "u1")
+	if budget != (KarmaBudget{Days: 1}) {
+		t.Errorf("GetKarmaBudget without limits = %+v, want no usage for 1 day", budget)
+	}
+	if !budget.Allows(1000) || !budget.Allows(-1000) {
+		t.Errorf("budget without limits does not allow karma")
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"math"
+	"time"
+)
+
+// DecayPolicy defines how karma loses value over time in a channel
+// Decay is only applied when displaying karma, raw karma totals are kept untouched in the database
+type DecayPolicy struct {
+	// HalfLifeDays number of days after which karma is worth half, 0 disables it
+	HalfLifeDays int
+	// MonthlyPercent percentage of karma lost every 30 days until it reaches 0, 0 disables it
+	// Ignored when HalfLifeDays is configured
+	MonthlyPercent int
+}
+
+// Enabled returns true if the policy decays karma
+func (p DecayPolicy) Enabled() bool {
+	return p.HalfLifeDays > 0 || p.MonthlyPercent > 0
+}
+
+// Factor returns the value that karma given age ago keeps, from 1 (no decay) to 0
+func (p DecayPolicy) Factor(age time.Duration) float64 {
+	ageDays := age.Hours() / 24
+	if ageDays <= 0 {
+		return 1
+	}
+	if p.HalfLifeDays > 0 {
+		return math.Pow(0.5, ageDays/float64(p.HalfLifeDays))
+	}
+	if p.MonthlyPercent > 0 {
+		return math.Max(0, 1-float64(p.MonthlyPercent)/100*ageDays/30)
+	}
+	return 1
+}
+
+// GetDecayPolicy returns the decay policy configured for a channel
+func (db *Database) GetDecayPolicy(channel string) DecayPolicy {
+	return DecayPolicy{
+		HalfLifeDays:   db.GetIntSetting(channel, "karma_decay_half_life"),
+		MonthlyPercent: db.GetIntSetting(channel, "karma_decay_monthly_percent"),
+	}
+}
+
+// karmaRow holds the karma table values needed to apply decay
+type karmaRow struct {
+	karma          int
+	lastTimestamp  int64
+	resetTimestamp int64
+	loggedKarma    int
+	decayedKarma   float64
+	firstLogged    int64
+}
+
+// getChannelKarma returns the karma for the words in a channel (only for word if not empty) with the channel decay policy applied
+// Karma logged in karma_log decays based on when it was given, karma not present in the log (granted before the log
+// existed or set by admins) decays from the first logged karma, or from the last karma update if nothing was logged
+func (db *Database) getChannelKarma(channel string, word string) map[string]int {
+	// An empty word matches every word in the channel
+	wordFilter := " AND (? == '' OR word == ?)"
+	query := "SELECT word, karma, last_karma_timestamp, reset_timestamp FROM karma WHERE channel == ?" + wordFilter + ";"
+	rows := db.runQuery(query, channel, word, word)
+	karmaRows := map[string]*karmaRow{}
+	for rows.Next() {
+		var rowWord string
+		row := karmaRow{}
+		err := rows.Scan(&rowWord, &row.karma, &row.lastTimestamp, &row.resetTimestamp)
+		if err != nil {
+			panic(err)
+		}
+		karmaRows[rowWord] = &row
+	}
+	rows.Close()
+
+	karma := map[string]int{}
+	policy := db.GetDecayPolicy(channel)
+	if !policy.Enabled() {
+		for rowWord, row := range karmaRows {
+			karma[rowWord] = row.karma
+		}
+		return karma
+	}
+
+	now := time.Now()
+	query = "SELECT word, karma, timestamp FROM karma_log WHERE channel == ?" + wordFilter + ";"
+	rows = db.runQuery(query, channel, word, word)
+	defer rows.Close()
+	for rows.Next() {
+		var logWord string
+		var logKarma int
+		var logTimestamp int64
+		err := rows.Scan(&logWord, &logKarma, &logTimestamp)
+		if err != nil {
+			panic(err)
+		}
+		row, found := karmaRows[logWord]
+		// Karma given before a reset is no longer part of the word karma
+		if !found || logTimestamp < row.resetTimestamp {
+			continue
+		}
+		row.loggedKarma += logKarma
+		row.decayedKarma += float64(logKarma) * policy.Factor(now.Sub(time.Unix(logTimestamp, 0)))
+		if row.firstLogged == 0 || logTimestamp < row.firstLogged {
+			row.firstLogged = logTimestamp
+		}
+	}
+
+	for rowWord, row := range karmaRows {
+		unloggedTimestamp := row.lastTimestamp
+		if row.firstLogged > 0 {
+			unloggedTimestamp = row.firstLogged
+		}
+		unloggedKarma := float64(row.karma - row.loggedKarma)
+		decayedKarma := row.decayedKarma + unloggedKarma*policy.Factor(now.Sub(time.Unix(unloggedTimestamp, 0)))
+		karma[rowWord] = int(math.Round(decayedKarma))
+	}
+	return karma
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecayPolicyFactor(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name   string
+		policy DecayPolicy
+		age    time.Duration
+		want   float64
+	}{
+		{name: "disabled", age: 365 * day, want: 1},
+		{name: "no age", policy: DecayPolicy{HalfLifeDays: 10}, want: 1},
+		{name: "future", policy: DecayPolicy{HalfLifeDays: 10}, age: -day, want: 1},
+		{name: "half life", policy: DecayPolicy{HalfLifeDays: 10}, age: 10 * day, want: 0.5},
+		{name: "two half lives", policy: DecayPolicy{HalfLifeDays: 10}, age: 20 * day, want: 0.25},
+		{name: "half life wins", policy: DecayPolicy{HalfLifeDays: 10, MonthlyPercent: 100}, age: 10 * day, want: 0.5},
+		{name: "monthly", policy: DecayPolicy{MonthlyPercent: 10}, age: 30 * day, want: 0.9},
+		{name: "half a month", policy: DecayPolicy{MonthlyPercent: 10}, age: 15 * day, want: 0.95},
+		{name: "monthly never negative", policy: DecayPolicy{MonthlyPercent: 50}, age: 90 * day, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.Factor(test.age); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("%+v.Factor(%s) = %f, want %f", test.policy, test.age, got, test.want)
+			}
+		})
+	}
+}
+
+// giveKarma updates the karma of a word and records it in the karma log, like karma given in a message
+func giveKarma(db *Database, channel string, word string, karma int, timestamp int64) {
+	db.UpdateKarma(channel, word, karma, "u1", timestamp)
+	db.LogKarma(channel, word, "u1", karma, timestamp)
+}
+
+func TestDecayedKarma(t *testing.T) {
+	db := newTestDatabase(t)
+	db.SetSetting("general", "karma_decay_half_life", "10")
+	// Logged karma decays from when it was given
+	giveKarma(db, "general", "logged", 8, daysAgo(10))
+	// Karma granted before the karma log existed decays from the last karma update
+	db.UpdateKarma("general", "unlogged", 6, "u1", daysAgo(10))
+	// Unlogged karma decays from the first logged karma when the word has logged karma
+	db.UpdateKarma("general", "mixed", 6, "u1", daysAgo(20))
+	giveKarma(db, "general", "mixed", 4, daysAgo(10))
+	// Karma given before a reset does not count anymore
+	giveKarma(db, "general", "reset", 4, daysAgo(30))
+	giveKarma(db, "general", "reset", 4, daysAgo(10))
+	db.ResetKarma("general", "reset", "u2", daysAgo(5))
+	giveKarma(db, "general", "reset", 4, time.Now().Unix())
+	// Channels without a decay policy show the raw karma
+	giveKarma(db, "random", "logged", 8, daysAgo(10))
+
+	tests := []struct {
+		channel string
+		word    string
+		want    int
+		wantRaw int
+	}{
+		{channel: "general", word: "logged", want: 4, wantRaw: 8},
+		{channel: "general", word: "unlogged", want: 3, wantRaw: 6},
+		{channel: "general", word: "mixed", want: 5, wantRaw: 10},
+		{channel: "general", word: "reset", want: 4, wantRaw: 4},
+		{channel: "random", word: "logged", want: 8, wantRaw: 8},
+	}
+	for _, test := range tests {
+		t.Run(test.channel+"/"+test.word, func(t *testing.T) {
+			if got := db.GetDisplayKarma(test.channel, test.word); got != test.want {
+				t.Errorf("GetDisplayKarma = %d, want %d", got, test.want)
+			}
+			if got := db.GetCurrentKarma(test.channel, test.word); got != test.wantRaw {
+				t.Errorf("GetCurrentKarma = %d, want %d", got, test.wantRaw)
+			}
+		})
+	}
+
+	if got := db.GetGlobalKarma("logged"); got != 12 {
+		t.Errorf("GetGlobalKarma = %d, want 12", got)
+	}
+	if got, want := rankWords(db.GetKarmaRank("general", 1, 0, false)), []string{"1:mixed", "2:logged", "2:reset", "4:unlogged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decayed rank = %v, want %v", got, want)
+	}
+	// Decay can be switched off again, raw karma is kept
+	db.DelSetting("general", "karma_decay_half_life")
+	if got := db.GetDisplayKarma("general", "logged"); got != 8 {
+		t.Errorf("GetDisplayKarma without decay = %d, want 8", got)
+	}
+}
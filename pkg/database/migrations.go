@@ -0,0 +1,69 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+// HasMigration returns true if a one-time data migration already ran
+func (db *Database) HasMigration(name string) bool {
+	rows := db.runQuery("SELECT name FROM migrations WHERE name == ?;", name)
+	defer rows.Close()
+	return rows.Next()
+}
+
+// SetMigration records a one-time data migration as done, so it does not run again
+func (db *Database) SetMigration(name string) {
+	db.runStatement("INSERT INTO migrations(name, timestamp) values (?, ?)", name, time.Now().Unix())
+}
+
+// GetMigrationTimestamp returns when a one-time data migration ran, 0 if it did not run
+func (db *Database) GetMigrationTimestamp(name string) int64 {
+	rows := db.runQuery("SELECT timestamp FROM migrations WHERE name == ?;", name)
+	defer rows.Close()
+	var timestamp int64
+	for rows.Next() {
+		err := rows.Scan(&timestamp)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return timestamp
+}
+
+// RenameWord moves the karma, karma history and aliases of a word to a new word on every channel where the word
+// did not receive karma since before (a Unix timestamp), so words still in use are left as they are.
+// If the new word already has karma on a channel the karma of both words is added up
+func (db *Database) RenameWord(word string, newWord string, before int64) {
+	for _, channel := range db.getChannels(word) {
+		if db.getLastKarmaTimestamp(channel, word) >= before {
+			log.Printf("Word %s received karma in channel %s after %d, it is not moved to %s", word, channel, before, newWord)
+			continue
+		}
+		currentKarma := db.GetCurrentKarma(channel, word)
+		if db.GetCurrentKarma(channel, newWord) == -256256 {
+			db.runStatement("UPDATE karma SET word = ? WHERE word == ? AND channel == ?;", newWord, word, channel)
+		} else {
+			db.runStatement("UPDATE karma SET karma = karma + ? WHERE word == ? AND channel == ?;", currentKarma, newWord, channel)
+			db.runStatement("DELETE FROM karma WHERE word == ? AND channel == ?;", word, channel)
+		}
+		db.runStatement("UPDATE karma_log SET word = ? WHERE word == ? AND channel == ? AND timestamp < ?;", newWord, word, channel, before)
+		db.runStatement("UPDATE alias SET word = ? WHERE word == ? AND channel == ?;", newWord, word, channel)
+		db.runStatement("UPDATE alias SET alias = ? WHERE alias == ? AND channel == ?;", newWord, word, channel)
+		log.Printf("Moved %d karma from word %s to %s in channel %s", currentKarma, word, newWord, channel)
+	}
+}
+
+// getLastKarmaTimestamp returns when a word last received karma in a channel
+func (db *Database) getLastKarmaTimestamp(channel string, word string) int64 {
+	rows := db.runQuery("SELECT COALESCE(last_karma_timestamp, 0) FROM karma WHERE word == ? AND channel == ?;", word, channel)
+	defer rows.Close()
+	var timestamp int64
+	for rows.Next() {
+		err := rows.Scan(&timestamp)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return timestamp
+}
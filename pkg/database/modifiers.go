@@ -0,0 +1,33 @@
+package database
+
+// GetModifiers returns the custom karma modifiers configured for a channel and the karma each one gives
+func (db *Database) GetModifiers(channel string) map[string]int {
+	// Modifiers are free text typed by users, so values are passed as parameters instead of building the statement
+	query := "SELECT modifier, delta FROM modifiers WHERE channel == ?;"
+	rows := db.runQuery(query, channel)
+	defer rows.Close()
+	modifiers := map[string]int{}
+	for rows.Next() {
+		var modifier string
+		var delta int
+		err := rows.Scan(&modifier, &delta)
+		if err != nil {
+			panic(err)
+		}
+		modifiers[modifier] = delta
+	}
+	return modifiers
+}
+
+// SetModifier configures a custom karma modifier for a channel
+func (db *Database) SetModifier(channel string, modifier string, delta int) {
+	db.DelModifier(channel, modifier)
+	modifierInsert := "INSERT INTO modifiers(channel, modifier, delta) values (?, ?, ?)"
+	db.runStatement(modifierInsert, channel, modifier, delta)
+}
+
+// DelModifier removes a custom karma modifier from a channel
+func (db *Database) DelModifier(channel string, modifier string) {
+	modifierDelete := "DELETE FROM modifiers WHERE channel == ? AND modifier == ?"
+	db.runStatement(modifierDelete, channel, modifier)
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"github.com/mvazquezc/karma-bot/pkg/settings"
+)
+
+// WorkspaceScope is the channel used to store workspace-wide settings, channel names cannot contain "*"
+const WorkspaceScope = "*"
+
+// Sources of an effective setting value
+const (
+	SourceChannel   = "channel"
+	SourceWorkspace = "workspace"
+	SourceDefault   = "default"
+)
+
+// GetEffectiveSetting returns the value for a setting in a channel and where it comes from: the channel override,
+// the workspace-wide value or the setting default
+func (db *Database) GetEffectiveSetting(channel string, name string) (value string, source string) {
+	value = db.GetSetting(channel, name)
+	if len(value) > 0 && channel != WorkspaceScope {
+		return value, SourceChannel
+	}
+	value = db.GetSetting(WorkspaceScope, name)
+	if len(value) > 0 {
+		return value, SourceWorkspace
+	}
+	setting, found := settings.Get(name)
+	if !found {
+		log.Printf("Setting %s is not registered", name)
+		return "", SourceDefault
+	}
+	return setting.Default, SourceDefault
+}
+
+// GetSettingValue returns the effective value for a setting in a channel
+func (db *Database) GetSettingValue(channel string, name string) string {
+	value, _ := db.GetEffectiveSetting(channel, name)
+	return value
+}
+
+// GetIntSetting returns the value for an int setting in a channel
+func (db *Database) GetIntSetting(channel string, name string) int {
+	value, err := settings.ParseInt(db.GetSettingValue(channel, name))
+	if err != nil {
+		log.Printf("Invalid value for setting %s in channel %s, using default: %s", name, channel, err)
+		setting, _ := settings.Get(name)
+		value, _ = settings.ParseInt(setting.Default)
+	}
+	return value
+}
+
+// GetBoolSetting returns the value for a bool setting in a channel
+func (db *Database) GetBoolSetting(channel string, name string) bool {
+	value, err := settings.ParseBool(db.GetSettingValue(channel, name))
+	if err != nil {
+		log.Printf("Invalid value for setting %s in channel %s, using default: %s", name, channel, err)
+		setting, _ := settings.Get(name)
+		value, _ = settings.ParseBool(setting.Default)
+	}
+	return value
+}
+
+// GetDurationSetting returns the value for a duration setting in a channel
+func (db *Database) GetDurationSetting(channel string, name string) time.Duration {
+	value, err := settings.ParseDuration(db.GetSettingValue(channel, name))
+	if err != nil {
+		log.Printf("Invalid value for setting %s in channel %s, using default: %s", name, channel, err)
+		setting, _ := settings.Get(name)
+		value, _ = settings.ParseDuration(setting.Default)
+	}
+	return value
+}
+
+// GetListSetting returns the items for a list setting in a channel
+func (db *Database) GetListSetting(channel string, name string) []string {
+	return settings.SplitList(db.GetSettingValue(channel, name))
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestDatabase returns an empty database in a temporary directory
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := New(filepath.Join(t.TempDir(), "karma.db"))
+	db.Connect()
+	return &db
+}
+
+func TestGetEffectiveSetting(t *testing.T) {
+	tests := []struct {
+		name       string
+		workspace  string
+		channel    string
+		scope      string
+		wantValue  string
+		wantSource string
+	}{
+		{name: "default", scope: "general", wantValue: "5", wantSource: SourceDefault},
+		{name: "workspace", workspace: "7", scope: "general", wantValue: "7", wantSource: SourceWorkspace},
+		{name: "channel override", workspace: "7", channel: "3", scope: "general", wantValue: "3", wantSource: SourceChannel},
+		{name: "channel without workspace", channel: "3", scope: "general", wantValue: "3", wantSource: SourceChannel},
+		{name: "other channel", channel: "3", scope: "random", wantValue: "5", wantSource: SourceDefault},
+		{name: "other channel with workspace", workspace: "7", channel: "3", scope: "random", wantValue: "7", wantSource: SourceWorkspace},
+		{name: "workspace scope", workspace: "7", channel: "3", scope: WorkspaceScope, wantValue: "7", wantSource: SourceWorkspace},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			if len(test.workspace) > 0 {
+				db.SetSetting(WorkspaceScope, "karma_max_delta", test.workspace)
+			}
+			if len(test.channel) > 0 {
+				db.SetSetting("general", "karma_max_delta", test.channel)
+			}
+			value, source := db.GetEffectiveSetting(test.scope, "karma_max_delta")
+			if value != test.wantValue || source != test.wantSource {
+				t.Errorf("GetEffectiveSetting(%q) = (%q, %q), want (%q, %q)", test.scope, value, source, test.wantValue, test.wantSource)
+			}
+		})
+	}
+}
+
+func TestDelSettingFallsBack(t *testing.T) {
+	db := newTestDatabase(t)
+	db.SetSetting(WorkspaceScope, "karma_max_delta", "7")
+	db.SetSetting("general", "karma_max_delta", "3")
+	db.SetSetting("general", "karma_max_delta", "4")
+	if value := db.GetSettingValue("general", "karma_max_delta"); value != "4" {
+		t.Errorf("updated channel value = %q, want 4", value)
+	}
+	db.DelSetting("general", "karma_max_delta")
+	if value, source := db.GetEffectiveSetting("general", "karma_max_delta"); value != "7" || source != SourceWorkspace {
+		t.Errorf("value after deleting the channel override = (%q, %q), want (7, %s)", value, source, SourceWorkspace)
+	}
+	db.DelSetting(WorkspaceScope, "karma_max_delta")
+	if value, source := db.GetEffectiveSetting("general", "karma_max_delta"); value != "5" || source != SourceDefault {
+		t.Errorf("value after deleting the workspace value = (%q, %q), want (5, %s)", value, source, SourceDefault)
+	}
+}
+
+func TestGetSettingQuotes(t *testing.T) {
+	db := newTestDatabase(t)
+	db.SetSetting("it's", "karma_max_delta", "3")
+	if value := db.GetSetting("it's", "karma_max_delta"); value != "3" {
+		t.Errorf("GetSetting for a channel with quotes = %q, want 3", value)
+	}
+	if value := db.GetSetting("general", "foo'"); value != "" {
+		t.Errorf("GetSetting for an unknown setting with quotes = %q, want empty", value)
+	}
+}
+
+func TestTypedSettings(t *testing.T) {
+	db := newTestDatabase(t)
+	// Values stored by older versions, before settings were validated
+	db.SetSetting("legacy", "karma_cooldown", "30")
+	db.SetSetting("legacy", "use_karma_emojis", "1")
+	db.SetSetting("legacy", "notify_karma", "ten")
+	db.SetSetting("legacy", "karma_blocklist", "c++ g++,i++")
+	// Values stored by the setting commands
+	db.SetSetting("general", "karma_cooldown", "1m30s")
+	db.SetSetting("general", "use_karma_emojis", "true")
+	db.SetSetting("general", "notify_karma", "3")
+
+	tests := []struct {
+		channel      string
+		wantCooldown time.Duration
+		wantEmojis   bool
+		wantNotify   int
+		wantBlocked  []string
+	}{
+		{channel: "legacy", wantCooldown: 30 * time.Second, wantEmojis: true, wantNotify: 1, wantBlocked: []string{"c++", "g++", "i++"}},
+		{channel: "general", wantCooldown: 90 * time.Second, wantEmojis: true, wantNotify: 3, wantBlocked: []string{"c++", "g++", "i++"}},
+		{channel: "unknown", wantCooldown: 10 * time.Second, wantEmojis: false, wantNotify: 1, wantBlocked: []string{"c++", "g++", "i++"}},
+	}
+	for _, test := range tests {
+		t.Run(test.channel, func(t *testing.T) {
+			if got := db.GetDurationSetting(test.channel, "karma_cooldown"); got != test.wantCooldown {
+				t.Errorf("GetDurationSetting = %s, want %s", got, test.wantCooldown)
+			}
+			if got := db.GetBoolSetting(test.channel, "use_karma_emojis"); got != test.wantEmojis {
+				t.Errorf("GetBoolSetting = %t, want %t", got, test.wantEmojis)
+			}
+			if got := db.GetIntSetting(test.channel, "notify_karma"); got != test.wantNotify {
+				t.Errorf("GetIntSetting = %d, want %d", got, test.wantNotify)
+			}
+			if got := db.GetListSetting(test.channel, "karma_blocklist"); !reflect.DeepEqual(got, test.wantBlocked) {
+				t.Errorf("GetListSetting = %v, want %v", got, test.wantBlocked)
+			}
+		})
+	}
+}
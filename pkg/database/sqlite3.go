@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
@@ -15,6 +16,24 @@ type Database struct {
 	File string
 }
 
+// RankEntry holds the position of a word in a karma rank
+type RankEntry struct {
+	// Position uses competition ranking, words with the same karma share position and leave a gap (1, 2, 2, 4)
+	Position int
+	// DensePosition uses dense ranking, words with the same karma share position without gaps (1, 2, 2, 3)
+	DensePosition int
+	Word          string
+	Karma         int
+}
+
+// RankPage holds a page of a karma rank
+type RankPage struct {
+	Entries    []RankEntry
+	Page       int
+	TotalPages int
+	TotalWords int
+}
+
 // New Database constructor
 func New(dbFile string) Database {
 	db := Database{File: dbFile}
@@ -53,10 +72,53 @@ func (db *Database) Connect() {
 	} else {
 		panic(err)
 	}
+	db.migrateDatabase()
+}
+
+// migrateDatabase creates the tables added after the initial schema, so databases created by older versions keep working
+func (db *Database) migrateDatabase() {
+	statement := `
+        create table if not exists karma_log (channel text, word text, giver text, karma integer, timestamp integer);
+        create table if not exists budget_resets (channel text, user text, timestamp integer);
+        create table if not exists audit_log (channel text, actor text, command text, arguments text, before text, after text, timestamp integer);
+        create table if not exists templates (channel text, name text, template text);
+        create table if not exists modifiers (channel text, modifier text, delta integer);
+        create table if not exists migrations (name text, timestamp integer);
+        `
+	db.runStatement(statement)
+	db.addColumn("karma", "reset_timestamp", "integer default 0")
+	// Admins configured before roles existed could manage other admins, they keep doing so as owners
+	if db.addColumn("admins", "role", "text default 'admin'") {
+		db.runStatement("UPDATE admins SET role = 'owner';")
+	}
 }
 
-// runStatement runs a query into the db
-func (db *Database) runStatement(statement string) {
+// addColumn adds a column to an existing table if the column does not exist yet, returns true if the column was added
+func (db *Database) addColumn(table string, column string, definition string) bool {
+	rows := db.runQuery("PRAGMA table_info(" + table + ");")
+	var cid, notNull, primaryKey int
+	var name, columnType string
+	var defaultValue sql.NullString
+	columnExists := false
+	for rows.Next() {
+		err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			panic(err)
+		}
+		if name == column {
+			columnExists = true
+		}
+	}
+	rows.Close()
+	if !columnExists {
+		log.Printf("Adding column %s to table %s", column, table)
+		db.runStatement("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition + ";")
+	}
+	return !columnExists
+}
+
+// runStatement runs a query into the db, args replace the ? placeholders in the statement
+func (db *Database) runStatement(statement string, args ...interface{}) {
 	database, err := sql.Open("sqlite3", db.File)
 
 	if err != nil {
@@ -65,14 +127,15 @@ func (db *Database) runStatement(statement string) {
 
 	defer database.Close()
 
-	_, err = database.Exec(statement)
+	_, err = database.Exec(statement, args...)
 
 	if err != nil {
 		panic(err)
 	}
 }
 
-func (db *Database) runQuery(statement string) *sql.Rows {
+// runQuery runs a query into the db and returns the rows, args replace the ? placeholders in the statement
+func (db *Database) runQuery(statement string, args ...interface{}) *sql.Rows {
 	database, err := sql.Open("sqlite3", db.File)
 
 	if err != nil {
@@ -81,7 +144,7 @@ func (db *Database) runQuery(statement string) *sql.Rows {
 
 	defer database.Close()
 
-	rows, err := database.Query(statement)
+	rows, err := database.Query(statement, args...)
 
 	if err != nil {
 		panic(err)
@@ -161,8 +224,8 @@ func (db *Database) DelAlias(channel string, word string, alias string) {
 
 // GetAdmins returns admins for a given channel
 func (db *Database) GetAdmins(channel string) []string {
-	query := "SELECT user FROM admins WHERE channel == '" + channel + "';"
-	rows := db.runQuery(query)
+	query := "SELECT user FROM admins WHERE channel == ?;"
+	rows := db.runQuery(query, channel)
 	// we have to close the rows
 	defer rows.Close()
 
@@ -180,22 +243,44 @@ func (db *Database) GetAdmins(channel string) []string {
 	return admins
 }
 
-// CreateAdmin Creates a new admin in the database
-func (db *Database) CreateAdmin(channel string, user string) {
-	adminInsert := "INSERT INTO admins(channel, user) values (\"" + channel + "\",\"" + user + "\")"
-	db.runStatement(adminInsert)
+// CreateAdmin Creates a new admin with a given role (owner, admin or moderator) in the database
+func (db *Database) CreateAdmin(channel string, user string, role string) {
+	adminInsert := "INSERT INTO admins(channel, user, role) values (?, ?, ?)"
+	db.runStatement(adminInsert, channel, user, role)
+}
+
+// UpdateAdminRole Changes the role of an existing admin
+func (db *Database) UpdateAdminRole(channel string, user string, role string) {
+	adminUpdate := "UPDATE admins SET role = ? WHERE user == ? AND channel == ?;"
+	db.runStatement(adminUpdate, role, user, channel)
+}
+
+// GetRole returns the role of a user in a given channel, empty if the user is not an admin
+func (db *Database) GetRole(channel string, user string) string {
+	query := "SELECT role FROM admins WHERE user == ? AND channel == ?;"
+	rows := db.runQuery(query, user, channel)
+	defer rows.Close()
+
+	var role string
+	for rows.Next() {
+		err := rows.Scan(&role)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return role
 }
 
 // DeleteAdmin Deletes an admin from the database
 func (db *Database) DeleteAdmin(channel string, user string) {
-	adminDelete := "DELETE FROM admins WHERE user == '" + user + "' AND channel == '" + channel + "';"
-	db.runStatement(adminDelete)
+	adminDelete := "DELETE FROM admins WHERE user == ? AND channel == ?;"
+	db.runStatement(adminDelete, user, channel)
 }
 
 // GetSetting returns the value for a given setting
 func (db *Database) GetSetting(channel string, setting string) string {
-	query := "SELECT value FROM settings WHERE setting == '" + setting + "' AND channel == '" + channel + "';"
-	rows := db.runQuery(query)
+	query := "SELECT value FROM settings WHERE setting == ? AND channel == ?;"
+	rows := db.runQuery(query, setting, channel)
 	defer rows.Close()
 
 	var settingValue string
@@ -214,15 +299,21 @@ func (db *Database) SetSetting(channel string, settingName string, settingValue
 
 	if len(settingExists) <= 0 {
 		//Setting does not exist, run insert
-		settingInit := "INSERT INTO settings(channel, setting, value) values (\"" + channel + "\",\"" + settingName + "\"," + settingValue + ")"
-		db.runStatement(settingInit)
+		settingInit := "INSERT INTO settings(channel, setting, value) values (?, ?, ?)"
+		db.runStatement(settingInit, channel, settingName, settingValue)
 	} else {
 		//Setting does exist, run update
-		settingUpdate := "UPDATE settings SET value = " + settingValue + " WHERE setting == '" + settingName + "' AND channel == '" + channel + "';"
-		db.runStatement(settingUpdate)
+		settingUpdate := "UPDATE settings SET value = ? WHERE setting == ? AND channel == ?;"
+		db.runStatement(settingUpdate, settingValue, settingName, channel)
 	}
 }
 
+// DelSetting deletes a setting from a given channel, so the workspace or default value applies again
+func (db *Database) DelSetting(channel string, settingName string) {
+	settingDelete := "DELETE FROM settings WHERE setting == ? AND channel == ?;"
+	db.runStatement(settingDelete, settingName, channel)
+}
+
 // UpdateKarma updates the karma for a given word in a given channel
 func (db *Database) UpdateKarma(channel string, word string, karmaCounter int, lastKarmaUser string, lastKarmaTimestamp int64) (finalKarma string, notifyKarma bool, intFinalKarma int) {
 	// get current karma
@@ -239,14 +330,9 @@ func (db *Database) UpdateKarma(channel string, word string, karmaCounter int, l
 	// Update karma -> + (+int) = + || + (-int) = -
 	currentKarma += karmaCounter
 	// Check if we have to notify karma change based on setting
-	notifyKarmaSetting := db.GetSetting(channel, "notify_karma")
-
-	if len(notifyKarmaSetting) <= 0 {
-		notifyKarmaSetting = "1"
-	}
-	notifyKarmaSettingInt, _ := strconv.Atoi(notifyKarmaSetting)
+	notifyKarmaSetting := db.GetIntSetting(channel, "notify_karma")
 
-	if currentKarma%notifyKarmaSettingInt == 0 {
+	if notifyKarmaSetting <= 0 || currentKarma%notifyKarmaSetting == 0 {
 		notifyKarma = true
 	}
 	finalKarma = strconv.Itoa(currentKarma)
@@ -256,21 +342,112 @@ func (db *Database) UpdateKarma(channel string, word string, karmaCounter int, l
 	return finalKarma, notifyKarma, currentKarma
 }
 
-// GetGlobalKarma
+// LogKarma records the karma given by a user to a word, so we know who gives karma and when
+func (db *Database) LogKarma(channel string, word string, giver string, karma int, timestamp int64) {
+	karmaLogInsert := "INSERT INTO karma_log(channel, word, giver, karma, timestamp) values (?, ?, ?, ?, ?)"
+	db.runStatement(karmaLogInsert, channel, word, giver, karma, timestamp)
+}
+
+// GetGiversRank returns the users ranked by the positive (or negative) karma they gave in a channel since a given timestamp
+func (db *Database) GetGiversRank(channel string, since int64, negative bool) []RankEntry {
+	query := "SELECT giver, SUM(karma) AS total FROM karma_log WHERE channel == ? AND karma > 0 AND timestamp >= ? GROUP BY giver ORDER BY total DESC, giver ASC;"
+	if negative {
+		query = "SELECT giver, SUM(-karma) AS total FROM karma_log WHERE channel == ? AND karma < 0 AND timestamp >= ? GROUP BY giver ORDER BY total DESC, giver ASC;"
+	}
+	return db.getRankEntries(query, channel, since)
+}
+
+// GetKarmaGiven returns the positive and negative karma given by a user in a channel since a given timestamp
+func (db *Database) GetKarmaGiven(channel string, giver string, since int64) (positive int, negative int) {
+	query := "SELECT COALESCE(SUM(CASE WHEN karma > 0 THEN karma ELSE 0 END), 0), COALESCE(SUM(CASE WHEN karma < 0 THEN -karma ELSE 0 END), 0) FROM karma_log WHERE giver == ? AND channel == ? AND timestamp >= ?;"
+	return db.getKarmaTotals(query, giver, channel, since)
+}
+
+// GetKarmaReceived returns the positive and negative karma received by a word in a channel
+func (db *Database) GetKarmaReceived(channel string, word string) (positive int, negative int) {
+	query := "SELECT COALESCE(SUM(CASE WHEN karma > 0 THEN karma ELSE 0 END), 0), COALESCE(SUM(CASE WHEN karma < 0 THEN -karma ELSE 0 END), 0) FROM karma_log WHERE word == ? AND channel == ?;"
+	return db.getKarmaTotals(query, word, channel)
+}
+
+// getKarmaTotals runs a query returning a (positive, negative) karma pair, args replace the ? placeholders in the query
+func (db *Database) getKarmaTotals(query string, args ...interface{}) (positive int, negative int) {
+	rows := db.runQuery(query, args...)
+	defer rows.Close()
+
+	for rows.Next() {
+		err := rows.Scan(&positive, &negative)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return positive, negative
+}
+
+// GetTopBoostedWords returns the words that received the most positive karma from a user in a channel
+func (db *Database) GetTopBoostedWords(channel string, giver string, limit int) []RankEntry {
+	query := "SELECT word, SUM(karma) AS total FROM karma_log WHERE giver == ? AND channel == ? AND karma > 0 GROUP BY word ORDER BY total DESC, word ASC LIMIT ?;"
+	return db.getRankEntries(query, giver, channel, limit)
+}
+
+// GetTopBoosters returns the users that gave the most positive karma to a word in a channel
+func (db *Database) GetTopBoosters(channel string, word string, limit int) []RankEntry {
+	query := "SELECT giver, SUM(karma) AS total FROM karma_log WHERE word == ? AND channel == ? AND karma > 0 GROUP BY giver ORDER BY total DESC, giver ASC LIMIT ?;"
+	return db.getRankEntries(query, word, channel, limit)
+}
+
+// GetKarmaDays returns the days (YYYY-MM-DD, newest first) in which a word received positive karma in a channel
+func (db *Database) GetKarmaDays(channel string, word string) []string {
+	query := "SELECT DISTINCT date(timestamp, 'unixepoch', 'localtime') AS day FROM karma_log WHERE word == ? AND channel == ? AND karma > 0 ORDER BY day DESC;"
+	rows := db.runQuery(query, word, channel)
+	defer rows.Close()
+
+	var day string
+	var days []string
+
+	for rows.Next() {
+		err := rows.Scan(&day)
+		if err != nil {
+			panic(err)
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// GetGlobalKarma returns the karma for a word across all channels, with each channel decay policy applied
 func (db *Database) GetGlobalKarma(word string) int {
-	query := "SELECT karma FROM karma WHERE word == '" + word + "';"
+	var globalKarma int
+	for _, channel := range db.getChannels(word) {
+		globalKarma += db.getChannelKarma(channel, word)[word]
+	}
+	return globalKarma
+}
+
+// GetDisplayKarma returns the karma for a word in a channel with the channel decay policy applied
+// Unlike GetCurrentKarma, it returns 0 for words without karma
+func (db *Database) GetDisplayKarma(channel string, word string) int {
+	return db.getChannelKarma(channel, word)[word]
+}
+
+// getChannels returns the channels with karma (for a given word if not empty)
+func (db *Database) getChannels(word string) []string {
+	query := "SELECT DISTINCT channel FROM karma;"
+	if len(word) > 0 {
+		query = "SELECT DISTINCT channel FROM karma WHERE word == '" + word + "';"
+	}
 	rows := db.runQuery(query)
 	defer rows.Close()
-	var result int
-	var globalKarma int
+
+	var channel string
+	var channels []string
 	for rows.Next() {
-		err := rows.Scan(&result)
-		globalKarma += result
+		err := rows.Scan(&channel)
 		if err != nil {
 			panic(err)
 		}
+		channels = append(channels, channel)
 	}
-	return globalKarma
+	return channels
 }
 
 // GetCurrentKarma returns the current karma for an specific word
@@ -289,7 +466,21 @@ func (db *Database) GetCurrentKarma(channel string, word string) int {
 	return result
 }
 
-// KarmaCooldownTimeout returns true if the user/word cooldown (10s) is completed
+// ResetKarma sets the karma for a word in a channel back to 0
+// karma logged before the reset is no longer taken into account when applying decay
+func (db *Database) ResetKarma(channel string, word string, user string, timestamp int64) string {
+	currentKarma := db.GetCurrentKarma(channel, word)
+	if currentKarma == -256256 {
+		currentKarma = 0
+	}
+	// By adding the negative (or positive) current karma we reset the counter to 0
+	finalKarma, _, _ := db.UpdateKarma(channel, word, currentKarma*-1, user, timestamp)
+	karmaReset := "UPDATE karma SET reset_timestamp = " + strconv.FormatInt(timestamp, 10) + " WHERE word == '" + word + "' AND channel == '" + channel + "';"
+	db.runStatement(karmaReset)
+	return finalKarma
+}
+
+// KarmaCooldownTimeout returns true if the user/word cooldown (karma_cooldown setting) is completed
 // This avoids same user spamming karma for a word
 func (db *Database) KarmaCooldownTimeout(channel string, word string, user string) bool {
 	query := "SELECT last_karma_user, last_karma_timestamp FROM karma WHERE word == '" + word + "' AND channel == '" + channel + "';"
@@ -309,61 +500,129 @@ func (db *Database) KarmaCooldownTimeout(channel string, word string, user strin
 	if lastKarmaUser != user {
 		return true
 	}
-	// check cooldown 10s
-	if time.Now().Unix()-int64(lastKarmaTimestamp) > 10 {
+	// check cooldown
+	cooldown := db.GetDurationSetting(channel, "karma_cooldown")
+	if time.Since(time.Unix(int64(lastKarmaTimestamp), 0)) > cooldown {
 		return true
 	}
 	return false
 }
 
-// GetKarmaRank returns the rank of karma words for a specific channel
-func (db *Database) GetKarmaRank(channel string, returnAll bool) map[string]int {
-	var query string
-	if returnAll {
-		query = "SELECT word, karma FROM karma WHERE channel == '" + channel + "' ORDER BY karma DESC;"
-	} else {
-		query = "SELECT word, karma FROM karma WHERE channel == '" + channel + "' ORDER BY karma DESC LIMIT 10;"
+// GetKarmaRank returns a page of the karma rank for a specific channel, with the channel decay policy applied
+// pageSize 0 returns the whole rank, bottom returns the last pageSize words of the rank
+func (db *Database) GetKarmaRank(channel string, page int, pageSize int, bottom bool) RankPage {
+	return paginateRank(sortRank(db.getChannelKarma(channel, "")), page, pageSize, bottom)
+}
+
+// GetGlobalKarmaRank returns a page of the karma rank across all channels, with each channel decay policy applied
+// pageSize 0 returns the whole rank, bottom returns the last pageSize words of the rank
+func (db *Database) GetGlobalKarmaRank(page int, pageSize int, bottom bool) RankPage {
+	globalKarma := map[string]int{}
+	for _, channel := range db.getChannels("") {
+		for word, karma := range db.getChannelKarma(channel, "") {
+			globalKarma[word] += karma
+		}
 	}
-	rows := db.runQuery(query)
-	defer rows.Close()
+	return paginateRank(sortRank(globalKarma), page, pageSize, bottom)
+}
 
-	var word string
-	var karma int
+// GetKarmaRankSince returns a page of the rank of karma given in a channel since a given timestamp
+// Only karma recorded in the karma log is taken into account and decay is not applied, since periods are recent
+func (db *Database) GetKarmaRankSince(channel string, since int64, page int, pageSize int, bottom bool) RankPage {
+	query := "SELECT word, SUM(karma) AS total FROM karma_log WHERE channel == ? AND timestamp >= ? GROUP BY word ORDER BY total DESC, word ASC;"
+	return paginateRank(db.getRankEntries(query, channel, since), page, pageSize, bottom)
+}
 
-	rank := map[string]int{}
+// GetGlobalKarmaRankSince returns a page of the rank of karma given across channels since a given timestamp
+// Only karma recorded in the karma log is taken into account and decay is not applied, since periods are recent
+func (db *Database) GetGlobalKarmaRankSince(since int64, page int, pageSize int, bottom bool) RankPage {
+	query := "SELECT word, SUM(karma) AS total FROM karma_log WHERE timestamp >= ? GROUP BY word ORDER BY total DESC, word ASC;"
+	return paginateRank(db.getRankEntries(query, since), page, pageSize, bottom)
+}
 
-	for rows.Next() {
-		err := rows.Scan(&word, &karma)
-		if err != nil {
-			panic(err)
-		}
-		rank[word] = karma
+// sortRank returns a rank from a word/karma map, words with the same karma are ordered alphabetically
+// so ties are always listed in the same order
+func sortRank(karma map[string]int) []RankEntry {
+	rank := make([]RankEntry, 0, len(karma))
+	for word, wordKarma := range karma {
+		rank = append(rank, RankEntry{Word: word, Karma: wordKarma})
 	}
+	sort.Slice(rank, func(i, j int) bool {
+		if rank[i].Karma != rank[j].Karma {
+			return rank[i].Karma > rank[j].Karma
+		}
+		return rank[i].Word < rank[j].Word
+	})
+	setRankPositions(rank)
 	return rank
 }
 
-// GetGlobalKarmaRank returns the rank of karma words for all channels
-func (db *Database) GetGlobalKarmaRank(returnAll bool) map[string]int {
-	var query string
-	if returnAll {
-		query = "SELECT word, karma FROM karma ORDER BY karma DESC;"
-	} else {
-		query = "SELECT word, karma FROM karma ORDER BY karma DESC LIMIT 10;"
-	}
-	rows := db.runQuery(query)
+// getRankEntries runs a (word, karma) query already sorted by karma and computes the rank positions,
+// args replace the ? placeholders in the query
+func (db *Database) getRankEntries(query string, args ...interface{}) []RankEntry {
+	rows := db.runQuery(query, args...)
 	defer rows.Close()
 
 	var word string
 	var karma int
-
-	rank := map[string]int{}
+	var rank []RankEntry
 
 	for rows.Next() {
 		err := rows.Scan(&word, &karma)
 		if err != nil {
 			panic(err)
 		}
-		rank[word] = karma
+		rank = append(rank, RankEntry{Word: word, Karma: karma})
 	}
+	setRankPositions(rank)
 	return rank
 }
+
+// setRankPositions sets competition (1, 2, 2, 4) and dense (1, 2, 2, 3) positions on a rank sorted by karma
+func setRankPositions(rank []RankEntry) {
+	for i := range rank {
+		if i > 0 && rank[i].Karma == rank[i-1].Karma {
+			rank[i].Position = rank[i-1].Position
+			rank[i].DensePosition = rank[i-1].DensePosition
+			continue
+		}
+		rank[i].Position = i + 1
+		if i > 0 {
+			rank[i].DensePosition = rank[i-1].DensePosition + 1
+		} else {
+			rank[i].DensePosition = 1
+		}
+	}
+}
+
+// paginateRank returns the requested page of a rank
+func paginateRank(rank []RankEntry, page int, pageSize int, bottom bool) RankPage {
+	rankPage := RankPage{TotalWords: len(rank), Page: 1, TotalPages: 1}
+	if pageSize <= 0 || len(rank) == 0 {
+		rankPage.Entries = rank
+		return rankPage
+	}
+	rankPage.TotalPages = (len(rank) + pageSize - 1) / pageSize
+	if bottom {
+		start := len(rank) - pageSize
+		if start < 0 {
+			start = 0
+		}
+		rankPage.Page = rankPage.TotalPages
+		rankPage.Entries = rank[start:]
+		return rankPage
+	}
+	if page > rankPage.TotalPages {
+		page = rankPage.TotalPages
+	}
+	if page > 1 {
+		rankPage.Page = page
+	}
+	start := (rankPage.Page - 1) * pageSize
+	end := start + pageSize
+	if end > len(rank) {
+		end = len(rank)
+	}
+	rankPage.Entries = rank[start:end]
+	return rankPage
+}
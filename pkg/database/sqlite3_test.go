@@ -0,0 +1,219 @@
+package database
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// daysAgo returns the timestamp of noon a number of days ago, karma days are local dates so noon keeps them
+// apart regardless of the time tests run
+func daysAgo(days int) int64 {
+	year, month, day := time.Now().Date()
+	return time.Date(year, month, day, 12, 0, 0, 0, time.Local).AddDate(0, 0, -days).Unix()
+}
+
+// rankOf builds a rank sorted by karma from the given karma values
+func rankOf(karma ...int) []RankEntry {
+	var rank []RankEntry
+	for i, value := range karma {
+		rank = append(rank, RankEntry{Word: string(rune('a' + i)), Karma: value})
+	}
+	return rank
+}
+
+func TestSetRankPositions(t *testing.T) {
+	tests := []struct {
+		name          string
+		karma         []int
+		wantPositions []int
+		wantDense     []int
+	}{
+		{name: "empty"},
+		{name: "single", karma: []int{3}, wantPositions: []int{1}, wantDense: []int{1}},
+		{name: "no ties", karma: []int{5, 3, 1}, wantPositions: []int{1, 2, 3}, wantDense: []int{1, 2, 3}},
+		{name: "tie in the middle", karma: []int{5, 3, 3, 1}, wantPositions: []int{1, 2, 2, 4}, wantDense: []int{1, 2, 2, 3}},
+		{name: "tie on top", karma: []int{5, 5, 5, 1}, wantPositions: []int{1, 1, 1, 4}, wantDense: []int{1, 1, 1, 2}},
+		{name: "tie at the bottom", karma: []int{5, -1, -1}, wantPositions: []int{1, 2, 2}, wantDense: []int{1, 2, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rank := rankOf(test.karma...)
+			setRankPositions(rank)
+			var positions, dense []int
+			for _, entry := range rank {
+				positions = append(positions, entry.Position)
+				dense = append(dense, entry.DensePosition)
+			}
+			if !reflect.DeepEqual(positions, test.wantPositions) || !reflect.DeepEqual(dense, test.wantDense) {
+				t.Errorf("setRankPositions(%v) = %v / %v, want %v / %v", test.karma, positions, dense, test.wantPositions, test.wantDense)
+			}
+		})
+	}
+}
+
+func TestPaginateRank(t *testing.T) {
+	tests := []struct {
+		name           string
+		words          int
+		page           int
+		pageSize       int
+		bottom         bool
+		wantWords      string
+		wantPage       int
+		wantTotalPages int
+	}{
+		{name: "empty rank", pageSize: 3, wantPage: 1, wantTotalPages: 1},
+		{name: "no paging", words: 5, pageSize: 0, wantWords: "abcde", wantPage: 1, wantTotalPages: 1},
+		{name: "first page", words: 7, page: 1, pageSize: 3, wantWords: "abc", wantPage: 1, wantTotalPages: 3},
+		{name: "middle page", words: 7, page: 2, pageSize: 3, wantWords: "def", wantPage: 2, wantTotalPages: 3},
+		{name: "last partial page", words: 7, page: 3, pageSize: 3, wantWords: "g", wantPage: 3, wantTotalPages: 3},
+		{name: "page past the end", words: 7, page: 10, pageSize: 3, wantWords: "g", wantPage: 3, wantTotalPages: 3},
+		{name: "page zero", words: 7, page: 0, pageSize: 3, wantWords: "abc", wantPage: 1, wantTotalPages: 3},
+		{name: "bottom", words: 7, pageSize: 3, bottom: true, wantWords: "efg", wantPage: 3, wantTotalPages: 3},
+		{name: "bottom ignores page", words: 7, page: 1, pageSize: 3, bottom: true, wantWords: "efg", wantPage: 3, wantTotalPages: 3},
+		{name: "bottom smaller than a page", words: 2, pageSize: 3, bottom: true, wantWords: "ab", wantPage: 1, wantTotalPages: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			karma := make([]int, test.words)
+			for i := range karma {
+				karma[i] = test.words - i
+			}
+			rankPage := paginateRank(rankOf(karma...), test.page, test.pageSize, test.bottom)
+			words := ""
+			for _, entry := range rankPage.Entries {
+				words += entry.Word
+			}
+			if words != test.wantWords || rankPage.Page != test.wantPage || rankPage.TotalPages != test.wantTotalPages || rankPage.TotalWords != test.words {
+				t.Errorf("paginateRank(%d words, page %d, size %d, bottom %t) = %q page %d/%d of %d words, want %q page %d/%d of %d words",
+					test.words, test.page, test.pageSize, test.bottom, words, rankPage.Page, rankPage.TotalPages, rankPage.TotalWords,
+					test.wantWords, test.wantPage, test.wantTotalPages, test.words)
+			}
+		})
+	}
+}
+
+// rankWords returns the words of a rank page with their positions, like "1:foo"
+func rankWords(rankPage RankPage) []string {
+	var words []string
+	for _, entry := range rankPage.Entries {
+		words = append(words, strconv.Itoa(entry.Position)+":"+entry.Word)
+	}
+	return words
+}
+
+func TestGetKarmaRank(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now().Unix()
+	for word, karma := range map[string]int{"zeta": 5, "beta": 3, "alpha": 3, "gamma": 3, "delta": -2} {
+		db.UpdateKarma("general", word, karma, "u1", now)
+	}
+	db.UpdateKarma("random", "delta", 10, "u1", now)
+	db.UpdateKarma("random", "omega", 3, "u1", now)
+
+	tests := []struct {
+		name     string
+		rankPage RankPage
+		want     []string
+	}{
+		{name: "channel", rankPage: db.GetKarmaRank("general", 1, 0, false), want: []string{"1:zeta", "2:alpha", "2:beta", "2:gamma", "5:delta"}},
+		{name: "first page", rankPage: db.GetKarmaRank("general", 1, 2, false), want: []string{"1:zeta", "2:alpha"}},
+		{name: "ties across pages", rankPage: db.GetKarmaRank("general", 2, 2, false), want: []string{"2:beta", "2:gamma"}},
+		{name: "bottom", rankPage: db.GetKarmaRank("general", 1, 2, true), want: []string{"2:gamma", "5:delta"}},
+		{name: "global", rankPage: db.GetGlobalKarmaRank(1, 0, false), want: []string{"1:delta", "2:zeta", "3:alpha", "3:beta", "3:gamma", "3:omega"}},
+		{name: "other channel", rankPage: db.GetKarmaRank("random", 1, 0, false), want: []string{"1:delta", "2:omega"}},
+		{name: "unknown channel", rankPage: db.GetKarmaRank("unknown", 1, 10, false)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rankWords(test.rankPage); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("rank = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetKarmaRankSince(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now().Unix()
+	db.LogKarma("general", "old", "u1", 10, now-7200)
+	db.LogKarma("general", "foo", "u1", 2, now)
+	db.LogKarma("general", "bar", "u2", 1, now)
+	db.LogKarma("general", "bar", "u3", 1, now)
+	db.LogKarma("general", "baz", "u2", -1, now)
+	db.LogKarma("random", "baz", "u2", 5, now)
+
+	tests := []struct {
+		name     string
+		rankPage RankPage
+		want     []string
+	}{
+		{name: "channel", rankPage: db.GetKarmaRankSince("general", now-3600, 1, 0, false), want: []string{"1:bar", "1:foo", "3:baz"}},
+		{name: "whole log", rankPage: db.GetKarmaRankSince("general", 0, 1, 0, false), want: []string{"1:old", "2:bar", "2:foo", "4:baz"}},
+		{name: "global", rankPage: db.GetGlobalKarmaRankSince(now-3600, 1, 0, false), want: []string{"1:baz", "2:bar", "2:foo"}},
+		{name: "bottom", rankPage: db.GetGlobalKarmaRankSince(now-3600, 1, 1, true), want: []string{"2:foo"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rankWords(test.rankPage); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("rank = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKarmaLogQueries(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now().Unix()
+	db.LogKarma("general", "foo", "u1", 3, now)
+	db.LogKarma("general", "foo", "u1", -1, now)
+	db.LogKarma("general", "bar", "u1", 2, daysAgo(1))
+	db.LogKarma("general", "baz", "u1", 2, daysAgo(3))
+	db.LogKarma("general", "foo", "u2", 1, daysAgo(3))
+	db.LogKarma("general", "foo", "u3", 5, daysAgo(3))
+	db.LogKarma("general", "foo", "u4", -2, daysAgo(5))
+	db.LogKarma("random", "foo", "u1", 10, now)
+
+	t.Run("given", func(t *testing.T) {
+		if positive, negative := db.GetKarmaGiven("general", "u1", 0); positive != 7 || negative != 1 {
+			t.Errorf("GetKarmaGiven = (%d, %d), want (7, 1)", positive, negative)
+		}
+		if positive, negative := db.GetKarmaGiven("general", "u1", daysAgo(2)); positive != 5 || negative != 1 {
+			t.Errorf("GetKarmaGiven since 2 days ago = (%d, %d), want (5, 1)", positive, negative)
+		}
+		if positive, negative := db.GetKarmaGiven("general", "u9", 0); positive != 0 || negative != 0 {
+			t.Errorf("GetKarmaGiven for a user without karma = (%d, %d), want (0, 0)", positive, negative)
+		}
+	})
+	t.Run("received", func(t *testing.T) {
+		if positive, negative := db.GetKarmaReceived("general", "foo"); positive != 9 || negative != 3 {
+			t.Errorf("GetKarmaReceived = (%d, %d), want (9, 3)", positive, negative)
+		}
+	})
+	t.Run("givers rank", func(t *testing.T) {
+		if got, want := rankWords(RankPage{Entries: db.GetGiversRank("general", 0, false)}), []string{"1:u1", "2:u3", "3:u2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetGiversRank = %v, want %v", got, want)
+		}
+		if got, want := rankWords(RankPage{Entries: db.GetGiversRank("general", 0, true)}), []string{"1:u4", "2:u1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetGiversRank negative = %v, want %v", got, want)
+		}
+	})
+	t.Run("top boosted words", func(t *testing.T) {
+		if got, want := rankWords(RankPage{Entries: db.GetTopBoostedWords("general", "u1", 2)}), []string{"1:foo", "2:bar"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetTopBoostedWords = %v, want %v", got, want)
+		}
+	})
+	t.Run("top boosters", func(t *testing.T) {
+		if got, want := rankWords(RankPage{Entries: db.GetTopBoosters("general", "foo", 3)}), []string{"1:u3", "2:u1", "3:u2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetTopBoosters = %v, want %v", got, want)
+		}
+	})
+	t.Run("karma days", func(t *testing.T) {
+		want := []string{time.Unix(now, 0).Format("2006-01-02"), time.Unix(daysAgo(3), 0).Format("2006-01-02")}
+		if got := db.GetKarmaDays("general", "foo"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetKarmaDays = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package database
+
+// GetTemplate returns the message template configured for a channel, empty if the channel uses the default one
+func (db *Database) GetTemplate(channel string, name string) string {
+	// Templates are free text typed by users, so values are passed as parameters instead of building the statement
+	query := "SELECT template FROM templates WHERE channel == ? AND name == ?;"
+	rows := db.runQuery(query, channel, name)
+	defer rows.Close()
+	var template string
+	for rows.Next() {
+		err := rows.Scan(&template)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return template
+}
+
+// SetTemplate configures a message template for a channel
+func (db *Database) SetTemplate(channel string, name string, template string) {
+	db.DelTemplate(channel, name)
+	templateInsert := "INSERT INTO templates(channel, name, template) values (?, ?, ?)"
+	db.runStatement(templateInsert, channel, name, template)
+}
+
+// DelTemplate removes a message template from a channel, so the default one is used
+func (db *Database) DelTemplate(channel string, name string) {
+	templateDelete := "DELETE FROM templates WHERE channel == ? AND name == ?"
+	db.runStatement(templateDelete, channel, name)
+}
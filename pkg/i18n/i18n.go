@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage language used when a channel has no language configured, or a message is missing in a locale
+const DefaultLanguage = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalog holds the messages of every bundled locale by language and key
+var catalog = loadCatalog()
+
+// loadCatalog loads the bundled locales. Locales that cannot be loaded are skipped and messages missing in a locale
+// fall back to the default language, the problems are logged since they are caught by the tests before a release
+func loadCatalog() map[string]map[string]string {
+	locales := map[string]map[string]string{}
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		log.Printf("Cannot read the bundled locales: %s", err)
+		return locales
+	}
+	for _, file := range files {
+		content, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			log.Printf("Cannot read locale %s, skipping it: %s", file.Name(), err)
+			continue
+		}
+		messages := map[string]string{}
+		err = json.Unmarshal(content, &messages)
+		if err != nil {
+			log.Printf("Cannot load locale %s, skipping it: %s", file.Name(), err)
+			continue
+		}
+		locales[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	defaultMessages, found := locales[DefaultLanguage]
+	if !found {
+		log.Printf("Default locale %s not found, messages are shown as their keys", DefaultLanguage)
+		return locales
+	}
+	for language, messages := range locales {
+		for key := range defaultMessages {
+			if _, found := messages[key]; !found {
+				log.Printf("Message %s is missing in locale %s, using locale %s", key, language, DefaultLanguage)
+			}
+		}
+		for key := range messages {
+			if _, found := defaultMessages[key]; !found {
+				log.Printf("Message %s in locale %s does not exist in locale %s", key, language, DefaultLanguage)
+			}
+		}
+	}
+	return locales
+}
+
+// Languages returns the bundled languages
+func Languages() []string {
+	var languages []string
+	for language := range catalog {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Has returns true if the default language has a message for a key
+func Has(key string) bool {
+	_, found := catalog[DefaultLanguage][key]
+	return found
+}
+
+// T returns the message for a key in a language, placeholders like {word} are replaced using the args,
+// which are pairs of placeholder names and values
+func T(language string, key string, args ...string) string {
+	message, found := catalog[language][key]
+	if !found {
+		message, found = catalog[DefaultLanguage][key]
+		if !found {
+			log.Printf("Unknown message %s", key)
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	var replacements []string
+	for i := 0; i+1 < len(args); i += 2 {
+		replacements = append(replacements, "{"+args[i]+"}", args[i+1])
+	}
+	return strings.NewReplacer(replacements...).Replace(message)
+}
+
+// Error is an error whose message is in the message catalog, so errors shown to users can be translated
+type Error struct {
+	Key string
+	// Args pairs of placeholder names and values, like the args of T
+	Args []string
+}
+
+// NewError returns an error for a message of the catalog
+func NewError(key string, args ...string) error {
+	return &Error{Key: key, Args: args}
+}
+
+// Error returns the message in the default language, used when the error is logged
+func (e *Error) Error() string {
+	return T(DefaultLanguage, e.Key, e.Args...)
+}
+
+// ErrorMessage returns the message of an error in a language, errors that are not catalog errors are returned as they are
+func ErrorMessage(language string, err error) string {
+	var catalogError *Error
+	if errors.As(err, &catalogError) {
+		return T(language, catalogError.Key, catalogError.Args...)
+	}
+	return err.Error()
+}
@@ -0,0 +1,124 @@
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// placeholderRegex matches the {name} placeholders replaced by T, template variables like {{.word}} are not placeholders
+var placeholderRegex = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// readLocales reads the bundled locale files, the catalog skips broken locales so the files are read directly
+func readLocales(t *testing.T) map[string]map[string]string {
+	t.Helper()
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		t.Fatalf("cannot read locales: %s", err)
+	}
+	locales := map[string]map[string]string{}
+	for _, file := range files {
+		content, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			t.Fatalf("cannot read locale %s: %s", file.Name(), err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(content, &messages); err != nil {
+			t.Fatalf("cannot parse locale %s: %s", file.Name(), err)
+		}
+		locales[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	if _, found := locales[DefaultLanguage]; !found {
+		t.Fatalf("default locale %s not found", DefaultLanguage)
+	}
+	return locales
+}
+
+// placeholders returns the sorted placeholders of a message
+func placeholders(message string) []string {
+	var names []string
+	for _, match := range placeholderRegex.FindAllStringSubmatch(message, -1) {
+		names = append(names, match[1])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLocalesHaveTheSameKeys(t *testing.T) {
+	locales := readLocales(t)
+	defaultMessages := locales[DefaultLanguage]
+	for language, messages := range locales {
+		for key := range defaultMessages {
+			if _, found := messages[key]; !found {
+				t.Errorf("message %s is missing in locale %s", key, language)
+			}
+		}
+		for key := range messages {
+			if _, found := defaultMessages[key]; !found {
+				t.Errorf("message %s in locale %s does not exist in locale %s", key, language, DefaultLanguage)
+			}
+		}
+	}
+}
+
+func TestLocalesHaveTheSamePlaceholders(t *testing.T) {
+	locales := readLocales(t)
+	defaultMessages := locales[DefaultLanguage]
+	for language, messages := range locales {
+		for key, message := range messages {
+			defaultMessage, found := defaultMessages[key]
+			if !found {
+				continue
+			}
+			if got, want := placeholders(message), placeholders(defaultMessage); !reflect.DeepEqual(got, want) {
+				t.Errorf("message %s in locale %s has placeholders %v, locale %s has %v", key, language, got, DefaultLanguage, want)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		key      string
+		args     []string
+		want     string
+	}{
+		{name: "placeholders", language: "en", key: "admin.set.first_owner", args: []string{"user", "<@U1>"}, want: "User <@U1> configured as owner :white_check_mark:"},
+		{name: "unknown language", language: "xx", key: "admin.set.first_owner", args: []string{"user", "<@U1>"}, want: "User <@U1> configured as owner :white_check_mark:"},
+		{name: "unknown key", language: "en", key: "does.not.exist", want: "does.not.exist"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := T(test.language, test.key, test.args...); got != test.want {
+				t.Errorf("T(%q, %q) = %q, want %q", test.language, test.key, got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		err      error
+		want     string
+	}{
+		{name: "catalog error", language: "es", err: NewError("validation.int_range", "min", "1", "max", "10"), want: "se esperaba un valor entre 1 y 10"},
+		{name: "default language", language: "xx", err: NewError("validation.int_range", "min", "1", "max", "10"), want: "expected a value between 1 and 10"},
+		{name: "other error", language: "es", err: errors.New("template: message:1: unexpected"), want: "template: message:1: unexpected"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ErrorMessage(test.language, test.err); got != test.want {
+				t.Errorf("ErrorMessage(%q, %v) = %q, want %q", test.language, test.err, got, test.want)
+			}
+		})
+	}
+}
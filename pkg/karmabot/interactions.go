@@ -0,0 +1,83 @@
+package karmabot
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/commands"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
+	"github.com/slack-go/slack"
+)
+
+// handleInteraction handles the Slack interaction payloads sent when users click the buttons of the bot messages
+func handleInteraction(w http.ResponseWriter, r *http.Request, signingSecret string, api *slackcache.Client, cmds *commands.Commands) {
+	if !verifyRequest(r, signingSecret) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var callback slack.InteractionCallback
+	err := json.Unmarshal([]byte(r.FormValue("payload")), &callback)
+	if err != nil {
+		log.Printf("Cannot parse interaction payload: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// Slack expects interactions to be acknowledged within 3 seconds, messages are updated afterwards
+	w.WriteHeader(http.StatusOK)
+	if callback.Type != slack.InteractionTypeBlockActions {
+		log.Printf("Ignoring interaction of type %s", callback.Type)
+		return
+	}
+	for _, action := range callback.ActionCallback.BlockActions {
+		if strings.HasPrefix(action.BlockID, commands.RankBlockPrefix) {
+			// The block ID holds the rank group and the channel the rank belongs to
+			operationGroup, channelName := splitRankBlockID(strings.TrimPrefix(action.BlockID, commands.RankBlockPrefix))
+			go updateRank(api, cmds, callback, channelName, operationGroup, action.Value)
+		}
+	}
+}
+
+// splitRankBlockID returns the rank group and the channel of a rank block ID without its prefix,
+// messages sent by older versions have no channel
+func splitRankBlockID(blockID string) (operationGroup string, channelName string) {
+	parts := strings.SplitN(blockID, ":", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// updateRank renders the rank page selected with a rank button for the channel the rank belongs to and replaces
+// the original message with it, the rank is read from the channel where the button is clicked when channelName is empty
+func updateRank(api *slackcache.Client, cmds *commands.Commands, callback slack.InteractionCallback, channelName string, operationGroup string, operationArgs string) {
+	if operationGroup != "karma" && operationGroup != "globalkarma" {
+		log.Printf("Ignoring action for unknown rank %s", operationGroup)
+		return
+	}
+	// Ranks are updated once the interaction is acknowledged, outside of the HTTP handler, so database
+	// errors are recovered here instead of stopping the bot
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Cannot update %s rank for channel %s: %v", operationGroup, callback.Channel.ID, r)
+		}
+	}()
+	var err error
+	if len(channelName) <= 0 {
+		channelName, _, err = getChannelName(api, callback.Channel.ID)
+		if err != nil {
+			log.Printf("Cannot get channel information for channel %s: %s", callback.Channel.ID, err)
+			return
+		}
+	}
+	who := strings.ToLower(callback.User.ID)
+	log.Printf("Updating %s rank with %s in channel %s for user %s", operationGroup, operationArgs, channelName, who)
+	// Buttons are not invoked with a prefix, usage messages show the prefix of the channel
+	response := cmds.ProcessCommand(channelName, who, "", "rank", operationGroup, operationArgs)
+	options := append([]slack.MsgOption{slack.MsgOptionReplaceOriginal(callback.ResponseURL)}, responseOptions(response)...)
+	_, _, err = api.PostMessage(callback.Channel.ID, options...)
+	if err != nil {
+		log.Printf("Cannot update rank message: %s", err)
+	}
+}
@@ -0,0 +1,37 @@
+package karmabot
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mvazquezc/karma-bot/pkg/commands"
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/slack-go/slack"
+)
+
+func TestSplitRankBlockID(t *testing.T) {
+	tests := []struct {
+		blockID            string
+		wantOperationGroup string
+		wantChannelName    string
+	}{
+		{blockID: "karma:general", wantOperationGroup: "karma", wantChannelName: "general"},
+		{blockID: "globalkarma:d0123", wantOperationGroup: "globalkarma", wantChannelName: "d0123"},
+		{blockID: "karma", wantOperationGroup: "karma"},
+	}
+	for _, test := range tests {
+		t.Run(test.blockID, func(t *testing.T) {
+			operationGroup, channelName := splitRankBlockID(test.blockID)
+			if operationGroup != test.wantOperationGroup || channelName != test.wantChannelName {
+				t.Errorf("splitRankBlockID(%q) = (%q, %q), want (%q, %q)", test.blockID, operationGroup, channelName, test.wantOperationGroup, test.wantChannelName)
+			}
+		})
+	}
+}
+
+func TestUpdateRankRecoversDatabaseErrors(t *testing.T) {
+	// The database directory does not exist, so every query fails
+	db := database.New(filepath.Join(t.TempDir(), "missing", "karma.db"))
+	cmds := commands.New(&db, nil, commands.SuperAdmins{})
+	updateRank(nil, &cmds, slack.InteractionCallback{}, "general", "karma", "page 2")
+}
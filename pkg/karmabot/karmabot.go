@@ -3,24 +3,65 @@ package karmabot
 import (
 	"github.com/mvazquezc/karma-bot/pkg/commands"
 	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
 	"github.com/mvazquezc/karma-bot/pkg/utils"
 	"github.com/slack-go/slack"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Config karma bot configuration
+type Config struct {
+	APIToken    string
+	DBFile      string
+	SuperAdmins commands.SuperAdmins
+	// SigningSecret Slack app signing secret, the HTTP server for slash commands only starts if it is set
+	SigningSecret string
+	// ListenAddress address where the HTTP server listens
+	ListenAddress string
+	// MetricsAddress address where metrics are published, metrics are not published if it is empty
+	MetricsAddress string
+	// CacheTTL time Slack users, channels and channel members are cached, slackcache.DefaultTTL if it is 0
+	CacheTTL time.Duration
+	// MigrateUserKarma moves the karma older versions stored under user names to user IDs on start, it runs once
+	MigrateUserKarma bool
+}
+
+// commandRegex matches bot commands once the command prefix has been removed, keywords are case insensitive
+var commandRegex = regexp.MustCompile("(?i)^(set|get|del|rank) (karma|globalkarma|givers|profile|budget|audit|admin|setting|template|modifier|alias|help)(.*)$")
+
 // NewKarmaBot New bot
-func NewKarmaBot(apiToken string, dbFile string) {
+func NewKarmaBot(config Config) {
 
-	api := slack.New(apiToken)
+	// User, channel and channel members lookups are cached, the bot does them for every message
+	api := slackcache.New(slack.New(config.APIToken), config.CacheTTL)
 	rtm := api.NewRTM()
-	db := database.New(dbFile)
+	db := database.New(config.DBFile)
 	db.Connect()
-	commands := commands.New(&db)
+	// Users are stored by their ID, karma stored under their name by older versions is moved once when enabled
+	recordUserIDsRelease(db)
+	if config.MigrateUserKarma {
+		migrateUserWords(api, db)
+	}
+	commands := commands.New(&db, api, config.SuperAdmins)
 
 	go rtm.ManageConnection()
 
+	if len(config.MetricsAddress) > 0 {
+		go startMetricsServer(config.MetricsAddress)
+	}
+
+	if len(config.SigningSecret) > 0 {
+		// Buttons only work when Slack can send the interactions to the HTTP server
+		commands.EnableInteractivity()
+		go startHTTPServer(config, api, &db, &commands)
+	}
+
 	for msg := range rtm.IncomingEvents {
 		switch ev := msg.Data.(type) {
 		case *slack.MessageEvent:
@@ -34,7 +75,7 @@ func NewKarmaBot(apiToken string, dbFile string) {
 			var membersInformation []string
 
 			// Get conversation information
-			channelInformation, err := rtm.GetConversationInfo(ev.Channel, true)
+			channelInformation, err := api.GetConversationInfo(ev.Channel, false)
 
 			if err != nil {
 				log.Print("Ignoring message since we cannot get channel information")
@@ -54,122 +95,136 @@ func NewKarmaBot(apiToken string, dbFile string) {
 			var members []string
 
 			channelName = channelInformation.NameNormalized
+			// DMs have no name, we use the conversation ID to keep their karma and settings apart
+			isDM := channelInformation.IsIM || strings.HasPrefix(ev.Channel, "D")
+			if len(channelName) <= 0 {
+				channelName = strings.ToLower(ev.Channel)
+			}
 			members = membersInformation
 			//log.Printf("Channel name: %s, members: %s", channelName, members)
 			text := ev.Text
 			text = strings.TrimSpace(text)
-			text = strings.ToLower(text)
 
 			// Commands are implemented using a keyword rather than using slash commands to avoid
 			// having to publish the bot in order to receive webhooks
-			r := regexp.MustCompile("^(kb) (set|get|del|rank) (karma|globalkarma|admin|setting|alias|help)(.*)$")
-			matched := r.MatchString(text)
-			if matched {
-				captureGroups := r.FindStringSubmatch(text)
-				operation := captureGroups[2]
-				operationGroup := captureGroups[3]
-				operationArgs := captureGroups[4]
-				who := strings.ToLower(ev.User)
-				if operation == "get" && operationGroup == "help" {
-					log.Printf("Printing help on channel %s", channelName)
-					utils.PrintCommandsUsage(rtm, ev)
-				} else if operation == "set" && operationGroup == "karma" {
-					commandOutput := "Setting karma on channels with less than 3 people is not permitted :no_entry_sign:"
-					// A channel with only one person will have at least two members, person + karmabot
-					if len(members) > 2 {
-						commandOutput = commands.ProcessCommand(channelName, who, operation, operationGroup, operationArgs)
-					}
-					rtm.SendMessage(rtm.NewOutgoingMessage(commandOutput, ev.Channel))
-				} else {
-					// add user that fires the command to the args
-					commandOutput := commands.ProcessCommand(channelName, who, operation, operationGroup, operationArgs)
-					rtm.SendMessage(rtm.NewOutgoingMessage(commandOutput, ev.Channel))
+			// The keyword (command prefix) can be configured per channel, and the bot can be mentioned or sent a DM instead
+			commandPrefix := db.GetSettingValue(channelName, "command_prefix")
+			botMention := "<@" + info.User.ID + ">"
+			commandText, isCommand := utils.GetCommandText(text, commandPrefix, botMention, isDM)
+			if isCommand && ev.User != info.User.ID {
+				response, matched := runCommand(&commands, channelName, strings.ToLower(ev.User), commandText, commandPrefix, db.GetSettingValue(channelName, "language"), members)
+				if matched {
+					sendResponse(rtm, ev.Channel, ev.User, isDM, response)
 				}
 			}
-			splitText := strings.Fields(text)
-			splitText = utils.FixEmptyKarma(splitText)
+			karmaModifiers := utils.GetKarmaModifiers(db, channelName)
+			maxKarma := db.GetIntSetting(channelName, "karma_max_delta")
+			blocklist := db.GetListSetting(channelName, "karma_blocklist")
 			// Create empty slice, we will use it to remove duplicated words
 			var karmaWordsInMessage []string
-			for _, word := range splitText {
-				trimmedWord := strings.TrimSpace(word)
-				// Get rid of ``` at the start of the word, usually added by code blocks on slack
-				trimmedWord = strings.TrimLeft(trimmedWord, "```")
-				// Get rid of +++ at the start of the word, usually added by code patch outputs
-				trimmedWord = strings.TrimLeft(trimmedWord, "+++")
-				// Get rid of --- at the start of the word, usually added by code patch outputs
-				trimmedWord = strings.TrimLeft(trimmedWord, "---")
-				// If the message is code, we will ignore it \x60 -> ` (In slack, code snippets are surrounded by ``)
-				codeText := regexp.MustCompile("\x60")
-				isCodeText := codeText.MatchString(text)
-				r := regexp.MustCompile("(.[A-Za-z0-9äëïöüÄËÏÖÜñÑ<>@.-]+?)([+-]+)$")
-				matched := r.MatchString(trimmedWord)
-				captureGroups := r.FindStringSubmatch(trimmedWord)
-				if ev.User != info.User.ID && matched && !isCodeText {
-					karmaWord := captureGroups[1]
-					karmaModifier := captureGroups[2]
-					log.Printf("Karma word: %s, Karma modifier: %s, Channel: %s", karmaWord, karmaModifier, channelName)
-					// Get karmaModifier
-					karmaCounter := 0
-					switch karmaModifier {
-					case "++":
-						karmaCounter++
-					case "--":
-						karmaCounter--
-					case "+++":
-						karmaCounter += 2
-					case "---":
-						karmaCounter -= 2
-					default:
-						//ignore karma #ERRTOOMANYKARMA
-						log.Printf("Karma modifier %s not allowed", karmaModifier)
+			// The giver is told once per message when the karma budget runs out, even if many words get karma
+			budgetExhausted := false
+			for _, vote := range parser.Parse(text, karmaModifiers, blocklist) {
+				if ev.User == info.User.ID {
+					break
+				}
+				karmaWord := vote.Target
+				log.Printf("Karma word: %s, Karma modifier: %s, Channel: %s", karmaWord, vote.Modifier, channelName)
+				// Custom modifiers and explicit karma can give any karma, it is bounded by the maximum configured on the channel
+				karmaCounter := utils.CapKarma(vote.Delta, maxKarma)
+				if karmaCounter == 0 {
+					log.Printf("Karma modifier %s gives no karma, skipping", vote.Modifier)
+					continue
+				}
+
+				if vote.IsUser {
+					// Check that users are not giving karma to theirselfs
+					user := strings.ToLower("<@" + ev.User + ">")
+					if user == karmaWord {
+						log.Printf("User %s granted karma to theirself, skipping", user)
 						continue
 					}
-
-					if strings.HasPrefix(karmaWord, "<@") && strings.HasSuffix(karmaWord, ">") {
-						// Check that users are not giving karma to theirselfs
-						user := strings.ToLower("<@" + ev.User + ">")
-						if user == karmaWord {
-							log.Printf("User %s granted karma to theirself, skipping", user)
-							continue
-						}
-						// User can have an alias configured
-						alias := db.GetAlias(karmaWord, channelName)
-						if len(alias) > 0 {
-							log.Printf("User %s has an alias configured, skipping username retrieval", karmaWord)
-						} else {
-							karmaWord = utils.GetUsername(api, karmaWord)
+					// User can have an alias configured
+					karmaWord = utils.GetUserKarmaWord(db, karmaWord, channelName)
+				}
+				if vote.IsChannel {
+					karmaWord = utils.GetChannelKarmaWord(api, vote.ID)
+				}
+				// @here and user groups (depending on the user_group_karma setting) give karma to each of their members
+				var candidates []string
+				if vote.IsHere {
+					if !db.GetBoolSetting(channelName, "broadcast_karma") {
+						log.Printf("@%s karma is disabled on channel %s, skipping", vote.Broadcast, channelName)
+						continue
+					}
+					log.Printf("@%s detected, getting all users from the channel for the karma command", vote.Broadcast)
+					candidates = members
+				} else if vote.IsGroup && db.GetSettingValue(channelName, "user_group_karma") == "members" {
+					log.Printf("User group %s detected, getting its members for the karma command", vote.ID)
+					candidates = utils.GetUserGroupMembers(api, vote.ID)
+					if len(candidates) <= 0 {
+						continue
+					}
+				} else if vote.IsGroup {
+					karmaWord = utils.GetUserGroupKarmaWord(api, vote.ID)
+				}
+				if candidates != nil {
+					maxRecipients := db.GetIntSetting(channelName, "karma_max_recipients")
+					recipients, tooMany := utils.GetKarmaRecipients(api, candidates, vote.Broadcast, ev.User, maxRecipients)
+					if tooMany {
+						log.Printf("Karma for %s has more than %d recipients on channel %s, skipping", vote.Target, maxRecipients, channelName)
+						language := db.GetSettingValue(channelName, "language")
+						notice := i18n.T(language, "karma.too_many_recipients", "target", vote.Target, "max", strconv.Itoa(maxRecipients))
+						_, err := rtm.PostEphemeral(ev.Channel, ev.User, slack.MsgOptionText(notice, false))
+						if err != nil {
+							log.Printf("Error sending recipients notice to user %s: %s", ev.User, err)
 						}
+						continue
 					}
-					if karmaWord == "!here>" {
-						log.Printf("@here detected, getting all users from the channel for the karma command")
-						karmaWord = ""
-						for _, member := range members {
-							member = strings.ToLower("<@" + member + ">")
-							// User can have an alias configured
-							alias := db.GetAlias(member, channelName)
-							if len(alias) > 0 {
-								log.Printf("User %s has an alias configured, skipping username retrieval", member)
-								karmaWord = alias
-							} else {
-								karmaWord = utils.GetUsername(api, member)
-							}
-							// Avoid duplicated karma in the same message
-							if !utils.Contains(karmaWordsInMessage, karmaWord) {
-								utils.HandleKarma(rtm, ev, db, karmaWord, channelName, karmaCounter)
+					for _, member := range recipients {
+						member = strings.ToLower("<@" + member + ">")
+						// User can have an alias configured
+						karmaWord = utils.GetUserKarmaWord(db, member, channelName)
+						// Avoid duplicated karma in the same message
+						if !utils.Contains(karmaWordsInMessage, karmaWord) {
+							if utils.HandleKarma(rtm, api, ev, db, karmaWord, channelName, karmaCounter, vote.Reason, !budgetExhausted) {
+								budgetExhausted = true
 							}
-							karmaWordsInMessage = append(karmaWordsInMessage, karmaWord)
 						}
-						// Continue to next loop iteration since karma for @here is already managed
-						continue
+						karmaWordsInMessage = append(karmaWordsInMessage, karmaWord)
 					}
-					// Avoid duplicated karma in the same message
-					if !utils.Contains(karmaWordsInMessage, karmaWord) {
-						utils.HandleKarma(rtm, ev, db, karmaWord, channelName, karmaCounter)
+					// Continue to next loop iteration since karma for the members is already managed
+					continue
+				}
+				if len(karmaWord) <= 0 {
+					log.Printf("Cannot get the word for %s, skipping", vote.Target)
+					continue
+				}
+				// Avoid duplicated karma in the same message
+				if !utils.Contains(karmaWordsInMessage, karmaWord) {
+					if utils.HandleKarma(rtm, api, ev, db, karmaWord, channelName, karmaCounter, vote.Reason, !budgetExhausted) {
+						budgetExhausted = true
 					}
-					karmaWordsInMessage = append(karmaWordsInMessage, karmaWord)
 				}
+				karmaWordsInMessage = append(karmaWordsInMessage, karmaWord)
 			}
 
+		case *slack.UserChangeEvent:
+			api.InvalidateUser(ev.User.ID)
+
+		case *slack.MemberJoinedChannelEvent:
+			api.InvalidateMembers(ev.Channel)
+
+		case *slack.MemberLeftChannelEvent:
+			api.InvalidateMembers(ev.Channel)
+
+		case *slack.ChannelRenameEvent:
+			api.InvalidateChannel(ev.Channel.ID)
+
+		case *slack.GroupRenameEvent:
+			// Private channels are renamed with a group_rename event
+			api.InvalidateChannel(ev.Group.ID)
+
 		case *slack.RTMError:
 			log.Printf("Error %s\n", ev.Error())
 
@@ -181,3 +236,74 @@ func NewKarmaBot(apiToken string, dbFile string) {
 		}
 	}
 }
+
+// runCommand runs a bot command (the message without the command prefix) and returns its response
+// matched is false when the text is not a valid command
+func runCommand(cmds *commands.Commands, channelName string, who string, commandText string, commandPrefix string, language string, members []string) (response commands.Response, matched bool) {
+	captureGroups := commandRegex.FindStringSubmatch(commandText)
+	if captureGroups == nil {
+		return response, false
+	}
+	// Only the command keywords are folded, the arguments are kept as typed
+	operation := strings.ToLower(captureGroups[1])
+	operationGroup := strings.ToLower(captureGroups[2])
+	operationArgs := captureGroups[3]
+	if operation == "get" && operationGroup == "help" {
+		log.Printf("Printing help on channel %s", channelName)
+		response = commands.Response{Text: utils.GetCommandsUsage(commandPrefix, language), Visibility: commands.VisibilityEphemeral}
+	} else if operation == "set" && operationGroup == "karma" && len(members) <= 2 {
+		// A channel with only one person will have at least two members, person + karmabot
+		response = commands.Response{Text: i18n.T(language, "karma.set.small_channel"), Visibility: commands.VisibilityEphemeral}
+	} else {
+		// add user that fires the command to the args
+		response = cmds.ProcessCommand(channelName, who, commandPrefix, operation, operationGroup, operationArgs)
+	}
+	return response, true
+}
+
+// sendResponse sends a command response to the channel, only to the requester or by DM based on its visibility
+// In DMs every response is just posted to the DM
+func sendResponse(rtm *slack.RTM, channelID string, user string, isDM bool, response commands.Response) {
+	if isDM || response.Visibility == commands.VisibilityPublic {
+		// The RTM API cannot send blocks, responses with blocks are sent using the web API
+		if len(response.Blocks) > 0 {
+			_, _, err := rtm.PostMessage(channelID, responseOptions(response)...)
+			if err == nil {
+				return
+			}
+			log.Printf("Cannot send message with blocks, sending plain text instead: %s", err)
+		}
+		rtm.SendMessage(rtm.NewOutgoingMessage(response.Text, channelID))
+		return
+	}
+	if response.Visibility == commands.VisibilityDM {
+		err := sendDM(&rtm.Client, user, response)
+		if err == nil {
+			return
+		}
+		log.Printf("Cannot send DM to user %s, sending an ephemeral message instead: %s", user, err)
+	}
+	_, err := rtm.PostEphemeral(channelID, user, responseOptions(response)...)
+	if err != nil {
+		log.Printf("Cannot send ephemeral message to user %s: %s", user, err)
+	}
+}
+
+// sendDM sends a command response to a user in a DM
+func sendDM(api *slack.Client, user string, response commands.Response) error {
+	dmChannel, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{strings.ToUpper(user)}})
+	if err != nil {
+		return err
+	}
+	_, _, err = api.PostMessage(dmChannel.ID, responseOptions(response)...)
+	return err
+}
+
+// responseOptions returns the message options for a command response, the text is the fallback for the blocks
+func responseOptions(response commands.Response) []slack.MsgOption {
+	options := []slack.MsgOption{slack.MsgOptionText(response.Text, false)}
+	if len(response.Blocks) > 0 {
+		options = append(options, slack.MsgOptionBlocks(response.Blocks...))
+	}
+	return options
+}
@@ -0,0 +1,19 @@
+package karmabot
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+)
+
+// startMetricsServer starts the HTTP server that publishes the bot metrics, like the Slack cache hits and misses,
+// on /debug/vars. It is kept apart from the slash command server since metrics must not be exposed to Slack
+func startMetricsServer(metricsAddress string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Printf("Publishing metrics on %s", metricsAddress)
+	err := http.ListenAndServe(metricsAddress, mux)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,71 @@
+package karmabot
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
+	"github.com/mvazquezc/karma-bot/pkg/utils"
+)
+
+// userIDsMigration name of the migration that moves user karma from display names to user IDs
+const userIDsMigration = "user_ids"
+
+// userIDsRelease name of the marker recording the first start of a version storing user karma by user ID, words
+// receiving karma after it are regular words rather than user names stored by older versions
+const userIDsRelease = "user_ids_release"
+
+// recordUserIDsRelease records the first start of a version storing user karma by user ID, it runs on every start
+// so the user karma migration can be enabled later
+func recordUserIDsRelease(db database.Database) {
+	if !db.HasMigration(userIDsRelease) {
+		db.SetMigration(userIDsRelease)
+	}
+}
+
+// migrateUserWords moves the karma users received under their display name, as older versions stored it, to their
+// user ID. Names shared by several users cannot be resolved and are left as they are. A word can also be a regular
+// word matching a display name (like docker), so only words without karma since the user ID release are moved
+func migrateUserWords(api *slackcache.Client, db database.Database) {
+	if db.HasMigration(userIDsMigration) {
+		return
+	}
+	before := db.GetMigrationTimestamp(userIDsRelease)
+	users, err := api.GetUsers()
+	if err != nil {
+		log.Printf("Cannot get users to move their karma to user IDs, retrying on next start: %s", err)
+		return
+	}
+	mentions := map[string]string{}
+	ambiguousNames := map[string]bool{}
+	for _, user := range users {
+		mention := strings.ToLower("<@" + user.ID + ">")
+		// Older versions lowercased names instead of normalizing them, and removed ' from words
+		legacyName := user.Profile.DisplayNameNormalized
+		if len(legacyName) <= 0 {
+			legacyName = user.Profile.RealName
+		}
+		legacyName = strings.Replace(strings.ToLower(legacyName), " ", ".", -1)
+		for _, name := range []string{legacyName, utils.UserDisplayName(&user)} {
+			name = strings.ReplaceAll(name, "'", "")
+			if len(name) <= 0 {
+				continue
+			}
+			if previousMention, exists := mentions[name]; exists && previousMention != mention {
+				ambiguousNames[name] = true
+			}
+			mentions[name] = mention
+		}
+	}
+	for name, mention := range mentions {
+		if ambiguousNames[name] {
+			log.Printf("Name %s is shared by several users, its karma cannot be moved to a user ID", name)
+			continue
+		}
+		log.Printf("Moving karma of name %s to user %s", name, mention)
+		db.RenameWord(name, mention, before)
+	}
+	db.SetMigration(userIDsMigration)
+	log.Printf("Karma of %d user names moved to user IDs", len(mentions)-len(ambiguousNames))
+}
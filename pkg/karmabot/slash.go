@@ -0,0 +1,138 @@
+package karmabot
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mvazquezc/karma-bot/pkg/commands"
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
+	"github.com/slack-go/slack"
+)
+
+// slashCommand name of the slash command configured in the Slack app
+const slashCommand = "/karma"
+
+// startHTTPServer starts the HTTP server that receives the Slack slash command and interaction payloads
+func startHTTPServer(config Config, api *slackcache.Client, db *database.Database, cmds *commands.Commands) {
+	listenAddress := config.ListenAddress
+	if len(listenAddress) <= 0 {
+		listenAddress = ":8080"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/commands", func(w http.ResponseWriter, r *http.Request) {
+		handleSlashCommand(w, r, config.SigningSecret, api, db, cmds)
+	})
+	mux.HandleFunc("/slack/interactions", func(w http.ResponseWriter, r *http.Request) {
+		handleInteraction(w, r, config.SigningSecret, api, cmds)
+	})
+	log.Printf("Listening for slash commands and interactions on %s", listenAddress)
+	err := http.ListenAndServe(listenAddress, mux)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// verifyRequest checks the Slack signature of a request, the request body can still be read afterwards
+func verifyRequest(r *http.Request, signingSecret string) bool {
+	verifier, err := slack.NewSecretsVerifier(r.Header, signingSecret)
+	if err != nil {
+		log.Printf("Cannot verify request: %s", err)
+		return false
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Cannot read request body: %s", err)
+		return false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	_, err = verifier.Write(body)
+	if err == nil {
+		err = verifier.Ensure()
+	}
+	if err != nil {
+		log.Printf("Invalid request signature: %s", err)
+		return false
+	}
+	return true
+}
+
+// getChannelName returns the channel name used to store karma and settings, the same name used for messages
+func getChannelName(api *slackcache.Client, channelID string) (channelName string, members []string, err error) {
+	channelInformation, err := api.GetConversationInfo(channelID, false)
+	if err != nil {
+		return "", nil, err
+	}
+	members, _, err = api.GetUsersInConversation(&slack.GetUsersInConversationParameters{ChannelID: channelID})
+	if err != nil {
+		return "", nil, err
+	}
+	channelName = channelInformation.NameNormalized
+	// DMs have no name, we use the conversation ID to keep their karma and settings apart
+	if len(channelName) <= 0 {
+		channelName = strings.ToLower(channelID)
+	}
+	return channelName, members, nil
+}
+
+// handleSlashCommand runs the command received in a slash command payload and responds with its output
+func handleSlashCommand(w http.ResponseWriter, r *http.Request, signingSecret string, api *slackcache.Client, db *database.Database, cmds *commands.Commands) {
+	if !verifyRequest(r, signingSecret) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	slashCommandPayload, err := slack.SlashCommandParse(r)
+	if err != nil {
+		log.Printf("Cannot parse slash command: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if slashCommandPayload.Command != slashCommand {
+		log.Printf("Unknown slash command %s", slashCommandPayload.Command)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	commandText := strings.TrimSpace(slashCommandPayload.Text)
+	who := strings.ToLower(slashCommandPayload.UserID)
+	log.Printf("Received slash command %s %s in channel %s sent by user %s", slashCommandPayload.Command, commandText, slashCommandPayload.ChannelID, who)
+
+	response := slack.Msg{ResponseType: slack.ResponseTypeEphemeral}
+	channelName, members, err := getChannelName(api, slashCommandPayload.ChannelID)
+	if err != nil {
+		log.Printf("Cannot get channel information for channel %s: %s", slashCommandPayload.ChannelID, err)
+		response.Text = i18n.T(i18n.DefaultLanguage, "slash.channel_error")
+	} else {
+		if len(commandText) <= 0 {
+			commandText = "get help"
+		}
+		language := db.GetSettingValue(channelName, "language")
+		commandResponse, matched := runCommand(cmds, channelName, who, commandText, slashCommand, language, members)
+		response.Text = commandResponse.Text
+		response.Blocks = slack.Blocks{BlockSet: commandResponse.Blocks}
+		switch {
+		case !matched:
+			response.Blocks = slack.Blocks{}
+			response.Text = i18n.T(language, "slash.unknown_command", "command", slashCommand+" "+commandText, "help", slashCommand+" get help")
+		case commandResponse.Visibility == commands.VisibilityDM:
+			err = sendDM(api.Client, who, commandResponse)
+			if err == nil {
+				response.Text = i18n.T(language, "slash.sent_dm")
+				response.Blocks = slack.Blocks{}
+			} else {
+				log.Printf("Cannot send DM to user %s, sending an ephemeral response instead: %s", who, err)
+			}
+		case commandResponse.Visibility == commands.VisibilityPublic && db.GetSettingValue(channelName, "slash_command_response") == slack.ResponseTypeInChannel:
+			response.ResponseType = slack.ResponseTypeInChannel
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Cannot send slash command response: %s", err)
+	}
+}
@@ -0,0 +1,23 @@
+package parser
+
+import "regexp"
+
+// Slack mrkdwn regions where karma is not counted.
+// Slack escapes > as &gt; in message text, so both are accepted for quotes
+var (
+	codeBlockRegex  = regexp.MustCompile("(?s)```.*?```")
+	inlineCodeRegex = regexp.MustCompile("`[^`\n]+`")
+	// >>> quotes the rest of the message
+	multiQuoteRegex = regexp.MustCompile("(?s)(^|\n)[ \t]*(&gt;|>){3}.*$")
+	quoteRegex      = regexp.MustCompile("(?m)^[ \t]*(&gt;|>).*$")
+	strikeRegex     = regexp.MustCompile("~[^~\n]+~")
+)
+
+// StripFormatting removes code blocks, inline code, quotes and strikethrough text from a message,
+// unclosed markers are kept as they are since Slack shows them as plain text
+func StripFormatting(text string) string {
+	for _, region := range []*regexp.Regexp{codeBlockRegex, inlineCodeRegex, multiQuoteRegex, quoteRegex, strikeRegex} {
+		text = region.ReplaceAllString(text, " ")
+	}
+	return text
+}
@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxModifierLength maximum length of a custom karma modifier
+const maxModifierLength = 20
+
+// DefaultModifiers modifiers available on every channel and the karma they give
+var DefaultModifiers = map[string]int{"++": 1, "--": -1, "+++": 2, "---": -2}
+
+// KarmaVote karma given to a word or user in a message
+type KarmaVote struct {
+	// Target word receiving karma, users are mentions like <@u0123>
+	Target string
+	// Modifier modifier used to give karma, like ++ or +=3
+	Modifier string
+	// Delta karma given, before applying the channel limits
+	Delta int
+	// Reason text following the vote until the next vote, empty if there is none
+	Reason string
+	// IsUser true when the target is a user mention
+	IsUser bool
+	// IsHere true when the target is @here, @channel or @everyone, the karma goes to the members of the channel
+	IsHere bool
+	// Broadcast the broadcast mention used: here, channel or everyone, empty for other targets
+	Broadcast string
+	// IsGroup true when the target is a user group mention like <!subteam^s0123>
+	IsGroup bool
+	// IsChannel true when the target is a channel mention like <#c0123>
+	IsChannel bool
+	// ID Slack ID of the user, user group or channel mentioned, uppercase as the Slack API expects it
+	ID string
+}
+
+// wordCharacters characters words receiving karma are made of: letters and numbers in any script with their
+// combining marks, emoji (with skin tones and joiners), :emoji: codes, mentions and a few separators
+const wordCharacters = `[\p{L}\p{N}\p{M}\p{So}\x{1F3FB}-\x{1F3FF}\x{200D}\x{FE0F}<>@:._-]`
+
+// singleCharacterWord characters that are a word on their own, like 猫 or 🎉, other words need at least two characters
+// so things like x++ are not counted
+const singleCharacterWord = `[\p{Han}\p{Hiragana}\p{Katakana}\p{Hangul}\p{So}]`
+
+// mention user (<@u0123>), user group (<!subteam^s0123|@sre>), channel (<#c0123|general>) and
+// broadcast (<!here>) mentions, Slack may add a label after the ID
+const mention = `<(?:@|#|!subteam\^)[a-z0-9]+(?:\|[^<>]*)?>|<!(?:here|channel|everyone)(?:\|[^<>]*)?>`
+
+// broadcastRegex matches @here, @channel and @everyone, Slack may add a label like <!here|@here>
+var broadcastRegex = regexp.MustCompile(`^<!(here|channel|everyone)(?:\|[^<>]*)?>$`)
+
+// mentionRegex splits a mention into its kind and ID, the label is dropped
+var mentionRegex = regexp.MustCompile(`^<(@|#|!subteam\^)([a-z0-9]+)(?:\|[^<>]*)?>$`)
+
+// autocompletedMentionRegex matches user, group and channel mentions, Slack adds a space after them when autocompleted
+var autocompletedMentionRegex = regexp.MustCompile("(<[@#!])(.*)(>)")
+
+// targetRegex matches the end of a word that can receive karma, words can start with any character
+var targetRegex = regexp.MustCompile("(" + mention + "|." + wordCharacters + "+|" + singleCharacterWord + ")$")
+
+// numericKarmaRegex matches explicit karma like foo+=3 or foo-=3
+var numericKarmaRegex = regexp.MustCompile("(" + mention + "|." + wordCharacters + "+?|" + singleCharacterWord + ")([+-]=)([0-9]{1,6})$")
+
+// Normalize returns the canonical spelling of a text, so equivalent spellings land on the same karma row.
+// It applies NFKC normalization (full width letters, ligatures and composed accents are unified) and case folding
+func Normalize(text string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(text)))
+}
+
+// Parse returns the karma votes in a message as typed, karma targets are normalized with Normalize while reasons
+// keep the original text. Karma inside code, quotes and strikethrough text is not counted, nor words in the blocklist like c++
+func Parse(text string, modifiers map[string]int, blocklist []string) []KarmaVote {
+	words := FixEmptyKarma(FixNumericKarma(strings.Fields(StripFormatting(text))), modifiers)
+	var normalizedBlocklist []string
+	for _, blockedWord := range blocklist {
+		normalizedBlocklist = append(normalizedBlocklist, Normalize(blockedWord))
+	}
+	var votes []KarmaVote
+	for _, word := range words {
+		normalizedWord := Normalize(word)
+		vote, matched := parseWord(normalizedWord, modifiers)
+		if matched && (contains(normalizedBlocklist, normalizedWord) || contains(normalizedBlocklist, vote.Target)) {
+			matched = false
+		}
+		if !matched {
+			if len(votes) > 0 {
+				votes[len(votes)-1].Reason = strings.TrimSpace(votes[len(votes)-1].Reason + " " + word)
+			}
+			continue
+		}
+		votes = append(votes, vote)
+	}
+	return votes
+}
+
+// parseWord returns the karma vote in a single word
+func parseWord(word string, modifiers map[string]int) (KarmaVote, bool) {
+	// Get rid of +++ and --- at the start of the word, usually added by code patch outputs
+	word = strings.TrimLeft(word, "+++")
+	word = strings.TrimLeft(word, "---")
+	// Explicit karma like foo+=3 gives the points written, it takes precedence over custom modifiers
+	target, modifier, delta, matched := ParseNumericKarma(word)
+	if !matched {
+		target, modifier, matched = ParseModifier(word, modifiers)
+		delta = modifiers[modifier]
+	}
+	if !matched {
+		return KarmaVote{}, false
+	}
+	vote := KarmaVote{Target: target, Modifier: modifier, Delta: delta}
+	if captureGroups := broadcastRegex.FindStringSubmatch(target); captureGroups != nil {
+		vote.Target = "<!" + captureGroups[1] + ">"
+		vote.IsHere = true
+		vote.Broadcast = captureGroups[1]
+	}
+	if captureGroups := mentionRegex.FindStringSubmatch(target); captureGroups != nil {
+		vote.ID = strings.ToUpper(captureGroups[2])
+		switch captureGroups[1] {
+		case "@":
+			// Labels are dropped so <@u0123|bob> and <@u0123> are the same user
+			vote.Target = "<@" + captureGroups[2] + ">"
+			vote.IsUser = true
+		case "#":
+			vote.Target = "<#" + captureGroups[2] + ">"
+			vote.IsChannel = true
+		default:
+			vote.Target = "<!subteam^" + captureGroups[2] + ">"
+			vote.IsGroup = true
+		}
+	}
+	return vote, true
+}
+
+// FixEmptyKarma When user types @user and hits tab a space is inserted
+// that ends up in a space between the user handler and the karma modifier
+// this function will fix that by removing that space when detected
+func FixEmptyKarma(text []string, karmaModifiers map[string]int) []string {
+	var finalText []string
+	var finalIndex int = 0
+	for index, word := range text {
+		var newWord string = word
+
+		_, isModifier := karmaModifiers[word]
+		if isModifier && index > 0 && !strings.HasSuffix(finalText[finalIndex-1], word) {
+			newWord = text[index-1] + newWord
+			// We only want to fix the extra space added when pressing tab for autocomplete a user, group or channel handler
+			matched := autocompletedMentionRegex.MatchString(newWord)
+			if matched {
+				// The word preceding the karma modifiers and a space is a username, we want to fix it
+				finalText[finalIndex-1] = newWord
+			} else {
+				// Otherwise the modifier is kept as a word of its own, it is part of the karma reason
+				finalIndex++
+				finalText = append(finalText, word)
+			}
+		} else {
+			finalIndex++
+			finalText = append(finalText, newWord)
+		}
+	}
+	return finalText
+}
+
+// FixNumericKarma joins explicit karma typed with spaces, like foo += 3, foo +=3 or foo+= 3, into a single word (foo+=3)
+func FixNumericKarma(text []string) []string {
+	var finalText []string
+	for index := 0; index < len(text); index++ {
+		word := text[index]
+		if !numericKarmaRegex.MatchString(word) {
+			for joined := 2; joined >= 1; joined-- {
+				if index+joined < len(text) && numericKarmaRegex.MatchString(strings.Join(text[index:index+joined+1], "")) {
+					word = strings.Join(text[index:index+joined+1], "")
+					index += joined
+					break
+				}
+			}
+		}
+		finalText = append(finalText, word)
+	}
+	return finalText
+}
+
+// ParseModifier splits a word like foo++ into the word receiving karma and its modifier, the longest modifier
+// wins. Modifiers starting with + or - only match the whole run of + and - at the end of the word, so foo++++
+// does not match ++ unless ++++ is a modifier
+func ParseModifier(word string, modifiers map[string]int) (target string, modifier string, matched bool) {
+	for _, candidate := range sortModifiers(modifiers) {
+		if !strings.HasSuffix(word, candidate) {
+			continue
+		}
+		target = strings.TrimSuffix(word, candidate)
+		if strings.HasPrefix(candidate, "+") || strings.HasPrefix(candidate, "-") {
+			if strings.HasSuffix(target, "+") || strings.HasSuffix(target, "-") {
+				continue
+			}
+		}
+		target = targetRegex.FindString(target)
+		if len(target) > 0 {
+			return target, candidate, true
+		}
+	}
+	return "", "", false
+}
+
+// ParseNumericKarma splits explicit karma like foo+=3 into the word receiving karma, the modifier and the karma given
+func ParseNumericKarma(word string) (target string, modifier string, karma int, matched bool) {
+	captureGroups := numericKarmaRegex.FindStringSubmatch(word)
+	if captureGroups == nil || strings.HasSuffix(captureGroups[1], "+") || strings.HasSuffix(captureGroups[1], "-") {
+		return "", "", 0, false
+	}
+	karma, _ = strconv.Atoi(captureGroups[3])
+	if captureGroups[2] == "-=" {
+		karma = -karma
+	}
+	return captureGroups[1], captureGroups[2] + captureGroups[3], karma, true
+}
+
+// ValidateModifier checks a custom karma modifier can be told apart from the word receiving karma
+func ValidateModifier(modifier string) error {
+	if len(modifier) == 0 || utf8.RuneCountInString(modifier) > maxModifierLength || strings.ContainsAny(modifier, " \t\n<>@") {
+		return i18n.NewError("validation.modifier", "max", strconv.Itoa(maxModifierLength))
+	}
+	firstRune, _ := utf8.DecodeRuneInString(modifier)
+	if unicode.IsLetter(firstRune) || unicode.IsNumber(firstRune) {
+		return i18n.NewError("validation.modifier_start")
+	}
+	return nil
+}
+
+// sortModifiers returns the modifiers sorted from the longest to the shortest
+func sortModifiers(modifiers map[string]int) []string {
+	var sortedModifiers []string
+	for modifier := range modifiers {
+		sortedModifiers = append(sortedModifiers, modifier)
+	}
+	sort.Slice(sortedModifiers, func(i, j int) bool {
+		if len(sortedModifiers[i]) != len(sortedModifiers[j]) {
+			return len(sortedModifiers[i]) > len(sortedModifiers[j])
+		}
+		return sortedModifiers[i] < sortedModifiers[j]
+	})
+	return sortedModifiers
+}
+
+// contains returns true if a list contains a word
+func contains(list []string, word string) bool {
+	for _, item := range list {
+		if item == word {
+			return true
+		}
+	}
+	return false
+}
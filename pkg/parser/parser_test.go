@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		modifiers map[string]int
+		blocklist []string
+		want      []KarmaVote
+	}{
+		{name: "word", text: "foo++", want: []KarmaVote{{Target: "foo", Modifier: "++", Delta: 1}}},
+		{name: "negative", text: "foo--", want: []KarmaVote{{Target: "foo", Modifier: "--", Delta: -1}}},
+		{name: "longest modifier", text: "foo+++", want: []KarmaVote{{Target: "foo", Modifier: "+++", Delta: 2}}},
+		{name: "unknown modifier", text: "foo++++"},
+		{name: "single character", text: "x++"},
+		{name: "single character word", text: "猫++", want: []KarmaVote{{Target: "猫", Modifier: "++", Delta: 1}}},
+		{name: "no karma", text: "just a message"},
+		{name: "case folding", text: "Großartig++", want: []KarmaVote{{Target: "grossartig", Modifier: "++", Delta: 1}}},
+		{name: "full width", text: "ＦＯＯ++", want: []KarmaVote{{Target: "foo", Modifier: "++", Delta: 1}}},
+		{name: "reason as typed", text: "foo++ for the Great work", want: []KarmaVote{{Target: "foo", Modifier: "++", Delta: 1, Reason: "for the Great work"}}},
+		{
+			name: "reasons per vote",
+			text: "foo++ thanks bar-- meh",
+			want: []KarmaVote{{Target: "foo", Modifier: "++", Delta: 1, Reason: "thanks"}, {Target: "bar", Modifier: "--", Delta: -1, Reason: "meh"}},
+		},
+		{name: "explicit karma", text: "foo+=3", want: []KarmaVote{{Target: "foo", Modifier: "+=3", Delta: 3}}},
+		{name: "explicit karma with spaces", text: "foo -= 2", want: []KarmaVote{{Target: "foo", Modifier: "-=2", Delta: -2}}},
+		{name: "user", text: "<@U0123> ++", want: []KarmaVote{{Target: "<@u0123>", Modifier: "++", Delta: 1, IsUser: true, ID: "U0123"}}},
+		{name: "user with label", text: "<@U0123|bob>++", want: []KarmaVote{{Target: "<@u0123>", Modifier: "++", Delta: 1, IsUser: true, ID: "U0123"}}},
+		{name: "here", text: "<!here>++", want: []KarmaVote{{Target: "<!here>", Modifier: "++", Delta: 1, IsHere: true, Broadcast: "here"}}},
+		{name: "channel broadcast", text: "<!channel|@channel> ++", want: []KarmaVote{{Target: "<!channel>", Modifier: "++", Delta: 1, IsHere: true, Broadcast: "channel"}}},
+		{name: "user group", text: "<!subteam^S0123|@sre>++", want: []KarmaVote{{Target: "<!subteam^s0123>", Modifier: "++", Delta: 1, IsGroup: true, ID: "S0123"}}},
+		{name: "channel", text: "<#C0123|general>++", want: []KarmaVote{{Target: "<#c0123>", Modifier: "++", Delta: 1, IsChannel: true, ID: "C0123"}}},
+		{name: "blocklist", text: "C++ rocks", blocklist: []string{"c++"}},
+		{name: "inline code", text: "`foo++`"},
+		{name: "quote", text: "> foo++"},
+		{name: "strikethrough", text: "~foo++~"},
+		{name: "custom modifier", text: "foo:rocket:", modifiers: map[string]int{":rocket:": 3}, want: []KarmaVote{{Target: "foo", Modifier: ":rocket:", Delta: 3}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modifiers := test.modifiers
+			if modifiers == nil {
+				modifiers = DefaultModifiers
+			}
+			if got := Parse(test.text, modifiers, test.blocklist); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFixEmptyKarma(t *testing.T) {
+	tests := []struct {
+		name string
+		text []string
+		want []string
+	}{
+		{name: "autocompleted user", text: []string{"<@u0123>", "++", "thanks"}, want: []string{"<@u0123>++", "thanks"}},
+		{name: "autocompleted channel", text: []string{"<#c0123>", "--"}, want: []string{"<#c0123>--"}},
+		{name: "word", text: []string{"foo", "++"}, want: []string{"foo", "++"}},
+		{name: "modifier first", text: []string{"++", "foo"}, want: []string{"++", "foo"}},
+		{name: "repeated modifier", text: []string{"<@u0123>++", "++"}, want: []string{"<@u0123>++", "++"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FixEmptyKarma(test.text, DefaultModifiers); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FixEmptyKarma(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFixNumericKarma(t *testing.T) {
+	tests := []struct {
+		name string
+		text []string
+		want []string
+	}{
+		{name: "joined", text: []string{"foo+=3"}, want: []string{"foo+=3"}},
+		{name: "spaces around", text: []string{"foo", "+=", "3"}, want: []string{"foo+=3"}},
+		{name: "space before", text: []string{"foo", "-=3"}, want: []string{"foo-=3"}},
+		{name: "space after", text: []string{"foo+=", "3", "thanks"}, want: []string{"foo+=3", "thanks"}},
+		{name: "not a number", text: []string{"foo", "+=", "x"}, want: []string{"foo", "+=", "x"}},
+		{name: "no karma", text: []string{"foo", "bar"}, want: []string{"foo", "bar"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FixNumericKarma(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FixNumericKarma(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseModifier(t *testing.T) {
+	tests := []struct {
+		word         string
+		modifiers    map[string]int
+		wantTarget   string
+		wantModifier string
+		wantMatched  bool
+	}{
+		{word: "foo++", wantTarget: "foo", wantModifier: "++", wantMatched: true},
+		{word: "foo---", wantTarget: "foo", wantModifier: "---", wantMatched: true},
+		{word: "foo++++"},
+		{word: "foo"},
+		{word: "++"},
+		{word: "foo.bar++", wantTarget: "foo.bar", wantModifier: "++", wantMatched: true},
+		{word: "foo:rocket:", modifiers: map[string]int{":rocket:": 3, "++": 1}, wantTarget: "foo", wantModifier: ":rocket:", wantMatched: true},
+	}
+	for _, test := range tests {
+		t.Run(test.word, func(t *testing.T) {
+			modifiers := test.modifiers
+			if modifiers == nil {
+				modifiers = DefaultModifiers
+			}
+			target, modifier, matched := ParseModifier(test.word, modifiers)
+			if target != test.wantTarget || modifier != test.wantModifier || matched != test.wantMatched {
+				t.Errorf("ParseModifier(%q) = (%q, %q, %t), want (%q, %q, %t)", test.word, target, modifier, matched, test.wantTarget, test.wantModifier, test.wantMatched)
+			}
+		})
+	}
+}
+
+func TestStripFormatting(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "plain", text: "foo++ bar", want: []string{"foo++", "bar"}},
+		{name: "inline code", text: "`foo++` bar++", want: []string{"bar++"}},
+		{name: "code block", text: "```\nfoo++\n``` bar++", want: []string{"bar++"}},
+		{name: "quote", text: "&gt; foo++\nbar++", want: []string{"bar++"}},
+		{name: "multi line quote", text: "bar++\n>>> foo++\nbaz++", want: []string{"bar++"}},
+		{name: "strikethrough", text: "~foo++~ bar++", want: []string{"bar++"}},
+		{name: "unclosed marker", text: "`foo++", want: []string{"`foo++"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := strings.Fields(StripFormatting(test.text)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("StripFormatting(%q) = %q, want words %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{"foo++", "<@U0123> ++ thanks", "foo += 3", "<!here>++", "`foo++` bar--", "Großartig+++", "猫++ 🎉--"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, vote := range Parse(text, DefaultModifiers, []string{"c++"}) {
+			if len(vote.Target) == 0 || len(vote.Modifier) == 0 {
+				t.Errorf("Parse(%q) returned an empty target or modifier: %+v", text, vote)
+			}
+			if strings.Contains(vote.Target, vote.Modifier) {
+				t.Errorf("Parse(%q) returned target %q containing its modifier %q", text, vote.Target, vote.Modifier)
+			}
+		}
+	})
+}
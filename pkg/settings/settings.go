@@ -0,0 +1,220 @@
+package settings
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+)
+
+// Type of the value of a setting
+type Type string
+
+const (
+	// TypeInt integer values, bounded by Min and Max
+	TypeInt Type = "int"
+	// TypeBool true/false values, also accepts 1/0, yes/no and on/off
+	TypeBool Type = "bool"
+	// TypeDuration Go durations like 10s or 5m, bounded by Min and Max seconds
+	TypeDuration Type = "duration"
+	// TypeEnum one of Values
+	TypeEnum Type = "enum"
+	// TypeString free text up to Max characters
+	TypeString Type = "string"
+	// TypeEmojiList comma separated list of :emoji: codes
+	TypeEmojiList Type = "emoji list"
+	// TypeList comma separated list of words, up to Max items
+	TypeList Type = "list"
+)
+
+// Setting defines a channel setting
+type Setting struct {
+	Name    string
+	Type    Type
+	Default string
+	// Min and Max bound int values, duration seconds, string length and list items (0 means no bound for strings and lists)
+	Min int
+	Max int
+	// Values valid values for enum settings
+	Values []string
+	// Pattern optional regular expression string values must match
+	Pattern string
+}
+
+// registry holds every setting the bot understands, new settings must be registered here
+// The help of each setting is part of the message catalog, as setting.<name>
+var registry = []Setting{
+	{Name: "notify_karma", Type: TypeInt, Default: "1", Min: 1, Max: 1000},
+	{Name: "use_karma_emojis", Type: TypeBool, Default: "false"},
+	{Name: "positive_karma_emojis", Type: TypeEmojiList, Default: ":thumbsup:"},
+	{Name: "negative_karma_emojis", Type: TypeEmojiList, Default: ":thumbsdown:"},
+	{Name: "karma_max_delta", Type: TypeInt, Default: "5", Min: 1, Max: 1000},
+	{Name: "broadcast_karma", Type: TypeBool, Default: "true"},
+	{Name: "karma_max_recipients", Type: TypeInt, Default: "50", Min: 1, Max: 10000},
+	{Name: "user_group_karma", Type: TypeEnum, Default: "group", Values: []string{"group", "members"}},
+	{Name: "karma_blocklist", Type: TypeList, Default: "c++,g++,i++", Max: 100},
+	{Name: "karma_cooldown", Type: TypeDuration, Default: "10s", Min: 0, Max: 86400},
+	{Name: "karma_decay_half_life", Type: TypeInt, Default: "0", Min: 0, Max: 36500},
+	{Name: "karma_decay_monthly_percent", Type: TypeInt, Default: "0", Min: 0, Max: 100},
+	{Name: "karma_budget_positive", Type: TypeInt, Default: "0", Min: 0, Max: 100000},
+	{Name: "karma_budget_negative", Type: TypeInt, Default: "0", Min: 0, Max: 100000},
+	{Name: "karma_budget_days", Type: TypeInt, Default: "1", Min: 1, Max: 365},
+	{Name: "use_block_kit", Type: TypeBool, Default: "false"},
+	{Name: "command_response", Type: TypeEnum, Default: "public", Values: []string{"public", "ephemeral", "dm"}},
+	{Name: "slash_command_response", Type: TypeEnum, Default: "ephemeral", Values: []string{"ephemeral", "in_channel"}},
+	{Name: "command_prefix", Type: TypeString, Default: "kb", Max: 20, Pattern: `^[a-zA-Z0-9!$%&*.,;:?_~-]+$`},
+	{Name: "language", Type: TypeEnum, Default: i18n.DefaultLanguage, Values: i18n.Languages()},
+}
+
+// init logs the settings without a help message, so they are noticed when the bot starts instead of when the help is shown
+func init() {
+	for _, setting := range registry {
+		if !i18n.Has("setting." + setting.Name) {
+			log.Printf("Setting %s has no help message in the message catalog", setting.Name)
+		}
+	}
+}
+
+// emojiRegex matches Slack emoji codes like :thumbsup: or :+1:
+var emojiRegex = regexp.MustCompile(`^:[a-z0-9_+'-]+:$`)
+
+// All returns every registered setting
+func All() []Setting {
+	return registry
+}
+
+// Get returns a registered setting by name
+func Get(name string) (Setting, bool) {
+	for _, setting := range registry {
+		if setting.Name == name {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Names returns the names of every registered setting
+func Names() []string {
+	var names []string
+	for _, setting := range registry {
+		names = append(names, setting.Name)
+	}
+	return names
+}
+
+// Validate checks a value for the setting and returns it normalized, so it can be stored
+func (s Setting) Validate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch s.Type {
+	case TypeInt:
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return "", i18n.NewError("validation.int")
+		}
+		if intValue < s.Min || intValue > s.Max {
+			return "", i18n.NewError("validation.int_range", "min", strconv.Itoa(s.Min), "max", strconv.Itoa(s.Max))
+		}
+		return strconv.Itoa(intValue), nil
+	case TypeBool:
+		boolValue, err := parseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(boolValue), nil
+	case TypeDuration:
+		duration, err := time.ParseDuration(strings.ToLower(value))
+		if err != nil {
+			return "", i18n.NewError("validation.duration")
+		}
+		if duration < time.Duration(s.Min)*time.Second || duration > time.Duration(s.Max)*time.Second {
+			return "", i18n.NewError("validation.duration_range", "min", (time.Duration(s.Min) * time.Second).String(), "max", (time.Duration(s.Max) * time.Second).String())
+		}
+		return duration.String(), nil
+	case TypeEnum:
+		for _, validValue := range s.Values {
+			if strings.EqualFold(value, validValue) {
+				return validValue, nil
+			}
+		}
+		return "", i18n.NewError("validation.enum", "values", strings.Join(s.Values, ", "))
+	case TypeString:
+		if len(value) == 0 || (s.Max > 0 && len(value) > s.Max) {
+			return "", i18n.NewError("validation.string_length", "max", strconv.Itoa(s.Max))
+		}
+		if len(s.Pattern) > 0 && !regexp.MustCompile(s.Pattern).MatchString(value) {
+			return "", i18n.NewError("validation.string_pattern", "pattern", s.Pattern)
+		}
+		return value, nil
+	case TypeEmojiList:
+		emojis := SplitList(strings.ToLower(value))
+		if len(emojis) == 0 {
+			return "", i18n.NewError("validation.emoji_list")
+		}
+		for _, emoji := range emojis {
+			if !emojiRegex.MatchString(emoji) {
+				return "", i18n.NewError("validation.emoji", "emoji", emoji)
+			}
+		}
+		return strings.Join(emojis, ","), nil
+	case TypeList:
+		items := SplitList(value)
+		if len(items) == 0 || (s.Max > 0 && len(items) > s.Max) {
+			return "", i18n.NewError("validation.list", "max", strconv.Itoa(s.Max))
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", i18n.NewError("validation.type", "type", string(s.Type))
+}
+
+// Usage returns a description of the values accepted by the setting in a language
+func (s Setting) Usage(language string) string {
+	switch s.Type {
+	case TypeInt:
+		return i18n.T(language, "usage.int", "min", strconv.Itoa(s.Min), "max", strconv.Itoa(s.Max))
+	case TypeDuration:
+		return i18n.T(language, "usage.duration", "min", (time.Duration(s.Min) * time.Second).String(), "max", (time.Duration(s.Max) * time.Second).String())
+	case TypeEnum:
+		return strings.Join(s.Values, "|")
+	}
+	// Types are named like their messages, with underscores instead of spaces
+	return i18n.T(language, "usage."+strings.ReplaceAll(string(s.Type), " ", "_"))
+}
+
+// ParseInt returns the integer value of a stored setting
+func ParseInt(value string) (int, error) {
+	return strconv.Atoi(value)
+}
+
+// ParseBool returns the boolean value of a stored setting
+func ParseBool(value string) (bool, error) {
+	return parseBool(value)
+}
+
+// ParseDuration returns the duration value of a stored setting
+// Older versions stored durations as seconds, so plain integers are read as seconds
+func ParseDuration(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// SplitList returns the items of a comma (or space) separated list setting
+func SplitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// parseBool parses boolean values, 1/0 is accepted since older versions stored booleans as integers
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, i18n.NewError("validation.bool")
+}
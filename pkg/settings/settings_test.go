@@ -0,0 +1,88 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+)
+
+func TestSettingValidate(t *testing.T) {
+	tests := []struct {
+		setting string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{setting: "notify_karma", value: " 10 ", want: "10"},
+		{setting: "notify_karma", value: "0", wantErr: true},
+		{setting: "notify_karma", value: "1001", wantErr: true},
+		{setting: "notify_karma", value: "ten", wantErr: true},
+		{setting: "use_karma_emojis", value: "YES", want: "true"},
+		{setting: "use_karma_emojis", value: "0", want: "false"},
+		{setting: "use_karma_emojis", value: "maybe", wantErr: true},
+		{setting: "karma_cooldown", value: "90S", want: "1m30s"},
+		{setting: "karma_cooldown", value: "0s", want: "0s"},
+		{setting: "karma_cooldown", value: "25h", wantErr: true},
+		{setting: "karma_cooldown", value: "10", wantErr: true},
+		{setting: "command_response", value: "Ephemeral", want: "ephemeral"},
+		{setting: "command_response", value: "thread", wantErr: true},
+		{setting: "command_prefix", value: "KB!", want: "KB!"},
+		{setting: "command_prefix", value: "k b", wantErr: true},
+		{setting: "command_prefix", value: "averyveryverylongprefix", wantErr: true},
+		{setting: "positive_karma_emojis", value: ":Tada:, :+1:", want: ":tada:,:+1:"},
+		{setting: "positive_karma_emojis", value: ":tada:,rocket", wantErr: true},
+		{setting: "positive_karma_emojis", value: ",", wantErr: true},
+		{setting: "karma_blocklist", value: "c++, g++ i++", want: "c++,g++,i++"},
+		{setting: "karma_blocklist", value: " ", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.setting+"="+test.value, func(t *testing.T) {
+			setting, found := Get(test.setting)
+			if !found {
+				t.Fatalf("setting %s is not registered", test.setting)
+			}
+			got, err := setting.Validate(test.value)
+			if (err != nil) != test.wantErr || got != test.want {
+				t.Errorf("Validate(%q) = (%q, %v), want %q with error %t", test.value, got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	for _, setting := range All() {
+		if _, err := setting.Validate(setting.Default); err != nil {
+			t.Errorf("default %q of setting %s is not valid: %s", setting.Default, setting.Name, err)
+		}
+	}
+}
+
+func TestSettingsHaveHelp(t *testing.T) {
+	for _, setting := range All() {
+		if !i18n.Has("setting." + setting.Name) {
+			t.Errorf("setting %s has no help message", setting.Name)
+		}
+	}
+}
+
+func TestSettingUsage(t *testing.T) {
+	tests := []struct {
+		setting  string
+		language string
+		want     string
+	}{
+		{setting: "notify_karma", language: "en", want: "integer between 1 and 1000"},
+		{setting: "notify_karma", language: "de", want: "Ganzzahl zwischen 1 und 1000"},
+		{setting: "karma_cooldown", language: "en", want: "duration between 0s and 24h0m0s"},
+		{setting: "command_response", language: "es", want: "public|ephemeral|dm"},
+		{setting: "positive_karma_emojis", language: "es", want: "lista de emojis"},
+	}
+	for _, test := range tests {
+		t.Run(test.setting+"/"+test.language, func(t *testing.T) {
+			setting, _ := Get(test.setting)
+			if got := setting.Usage(test.language); got != test.want {
+				t.Errorf("Usage(%q) = %q, want %q", test.language, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package slackcache
+
+import (
+	"expvar"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// DefaultTTL time Slack users, channels and channel members are cached when no TTL is configured
+const DefaultTTL = 5 * time.Minute
+
+// PresenceTTL time user presences are cached, presence changes often so it is kept shorter than the TTL
+const PresenceTTL = 30 * time.Second
+
+// Kinds of cached data, used as key prefixes and in the metrics
+const (
+	kindUser     = "users"
+	kindChannel  = "channels"
+	kindMembers  = "members"
+	kindPresence = "presences"
+)
+
+// metrics hits, misses, invalidations and evictions per kind of cached data, like users_hits, published on /debug/vars
+var metrics = expvar.NewMap("slack_cache")
+
+// entry cached value and when it expires
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// evictionInterval minimum time between sweeps removing the expired entries from the cache
+const evictionInterval = time.Minute
+
+// Client Slack API client that caches the user, presence, channel and channel members lookups the bot does for every message.
+// Every other call goes straight to the embedded Slack client
+type Client struct {
+	*slack.Client
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]entry
+	// lastEviction last time the expired entries were removed
+	lastEviction time.Time
+}
+
+// New Client constructor, a TTL of 0 uses DefaultTTL
+func New(api *slack.Client, ttl time.Duration) *Client {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Client{Client: api, ttl: ttl, entries: map[string]entry{}, lastEviction: time.Now()}
+}
+
+// GetUserInfo returns the information of a user, from the cache if it has not expired
+func (c *Client) GetUserInfo(userID string) (*slack.User, error) {
+	value, err := c.get(kindUser, userID, c.ttl, func() (interface{}, error) {
+		return c.Client.GetUserInfo(userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*slack.User), nil
+}
+
+// GetConversationInfo returns the information of a channel, from the cache if it has not expired.
+// Requests including the locale are not cached
+func (c *Client) GetConversationInfo(channelID string, includeLocale bool) (*slack.Channel, error) {
+	if includeLocale {
+		return c.Client.GetConversationInfo(channelID, includeLocale)
+	}
+	value, err := c.get(kindChannel, channelID, c.ttl, func() (interface{}, error) {
+		return c.Client.GetConversationInfo(channelID, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*slack.Channel), nil
+}
+
+// GetUsersInConversation returns the members of a channel, from the cache if it has not expired.
+// Only the first page with the default limit is cached, requests for other pages go to Slack
+func (c *Client) GetUsersInConversation(params *slack.GetUsersInConversationParameters) ([]string, string, error) {
+	if len(params.Cursor) > 0 || params.Limit > 0 {
+		return c.Client.GetUsersInConversation(params)
+	}
+	type membersPage struct {
+		members []string
+		cursor  string
+	}
+	value, err := c.get(kindMembers, params.ChannelID, c.ttl, func() (interface{}, error) {
+		members, cursor, err := c.Client.GetUsersInConversation(params)
+		return membersPage{members: members, cursor: cursor}, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	page := value.(membersPage)
+	return page.members, page.cursor, nil
+}
+
+// GetUserPresence returns the presence of a user, from the cache if it is not older than PresenceTTL (or the TTL if shorter)
+func (c *Client) GetUserPresence(userID string) (*slack.UserPresence, error) {
+	ttl := PresenceTTL
+	if c.ttl < ttl {
+		ttl = c.ttl
+	}
+	value, err := c.get(kindPresence, userID, ttl, func() (interface{}, error) {
+		return c.Client.GetUserPresence(userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*slack.UserPresence), nil
+}
+
+// InvalidateUser removes a user from the cache, used when the user changes its profile
+func (c *Client) InvalidateUser(userID string) {
+	c.invalidate(kindUser, userID)
+}
+
+// InvalidateChannel removes a channel from the cache, used when the channel is renamed
+func (c *Client) InvalidateChannel(channelID string) {
+	c.invalidate(kindChannel, channelID)
+}
+
+// InvalidateMembers removes the members of a channel from the cache, used when someone joins or leaves the channel
+func (c *Client) InvalidateMembers(channelID string) {
+	c.invalidate(kindMembers, channelID)
+}
+
+// get returns a cached value, loading it from Slack and caching it for ttl if it is not cached or it expired.
+// Errors are not cached
+func (c *Client) get(kind string, id string, ttl time.Duration, load func() (interface{}, error)) (interface{}, error) {
+	key := kind + ":" + id
+	c.mutex.Lock()
+	cached, found := c.entries[key]
+	c.mutex.Unlock()
+	if found && time.Now().Before(cached.expires) {
+		metrics.Add(kind+"_hits", 1)
+		return cached.value, nil
+	}
+	metrics.Add(kind+"_misses", 1)
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.entries[key] = entry{value: value, expires: time.Now().Add(ttl)}
+	c.evictExpired()
+	c.mutex.Unlock()
+	return value, nil
+}
+
+// evictExpired removes the expired entries so users and channels that are not looked up again do not stay in memory,
+// it sweeps the cache at most once per evictionInterval. The mutex must be held
+func (c *Client) evictExpired() {
+	now := time.Now()
+	if now.Sub(c.lastEviction) < evictionInterval {
+		return
+	}
+	c.lastEviction = now
+	for key, cached := range c.entries {
+		if now.After(cached.expires) {
+			delete(c.entries, key)
+			metrics.Add(strings.SplitN(key, ":", 2)[0]+"_evictions", 1)
+		}
+	}
+}
+
+// invalidate removes a value from the cache
+func (c *Client) invalidate(kind string, id string) {
+	key := kind + ":" + id
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, found := c.entries[key]; found {
+		log.Printf("Removing %s %s from the Slack cache", kind, id)
+		delete(c.entries, key)
+		metrics.Add(kind+"_invalidations", 1)
+	}
+}
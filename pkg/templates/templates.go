@@ -0,0 +1,136 @@
+package templates
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+)
+
+// maxTemplateLength maximum length of a template and of the messages it renders
+const maxTemplateLength = 500
+
+// Data variables available in templates as {{.word}}, {{.karma}}, {{.delta}}, {{.giver}}, {{.global}} and {{.reason}}
+type Data struct {
+	// Word the word (or user) whose karma changed
+	Word string
+	// Karma karma of the word on the channel
+	Karma int
+	// Delta karma given or taken
+	Delta int
+	// Giver mention of the user that gave the karma
+	Giver string
+	// Global karma of the word across channels
+	Global int
+	// Reason text following the karma, empty if there is none
+	Reason string
+}
+
+// Variables names of the variables available in templates
+var Variables = []string{"word", "karma", "delta", "giver", "global", "reason"}
+
+// registry holds the name of every message template the bot renders, new templates must be registered here
+// Default templates and their help are part of the message catalog, as template.<name> and template.<name>.help
+var registry = []string{"karma_notification", "karma_value", "karma_set", "karma_reset"}
+
+// init logs the templates without a default or a help message, so they are noticed when the bot starts instead of when rendered
+func init() {
+	for _, name := range registry {
+		if !i18n.Has("template."+name) || !i18n.Has("template."+name+".help") {
+			log.Printf("Template %s has no default or help message in the message catalog", name)
+		}
+	}
+}
+
+// slackEscapes characters Slack escapes or replaces in messages, they are restored so templates can use them
+var slackEscapes = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "“", "\"", "”", "\"", "‘", "'", "’", "'")
+
+// Names returns the names of every registered template
+func Names() []string {
+	return registry
+}
+
+// Exists returns true if a template is registered
+func Exists(name string) bool {
+	for _, templateName := range registry {
+		if templateName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Default returns the default template for a language
+func Default(language string, name string) string {
+	return i18n.T(language, "template."+name)
+}
+
+// Help returns the description of a template for a language
+func Help(language string, name string) string {
+	return i18n.T(language, "template."+name+".help")
+}
+
+// SampleData returns the values used to preview templates
+func SampleData(giver string) Data {
+	return Data{Word: "coffee", Karma: 42, Delta: 1, Giver: giver, Global: 108, Reason: "for brewing the morning pot"}
+}
+
+// Normalize restores the characters Slack escapes in a template typed in a message
+func Normalize(text string) string {
+	return strings.TrimSpace(slackEscapes.Replace(text))
+}
+
+// Validate checks that a template can be parsed and rendered with every variable, and returns it rendered with the sample data
+func Validate(text string) (string, error) {
+	if len(text) == 0 || len(text) > maxTemplateLength {
+		return "", i18n.NewError("validation.template_length", "max", strconv.Itoa(maxTemplateLength))
+	}
+	return Render(text, SampleData("<@U00000000>"))
+}
+
+// Render renders a template with the given data, unknown variables are errors
+func Render(text string, data Data) (string, error) {
+	parsedTemplate, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	// Templates can loop, the output is limited while it is rendered so a template cannot exhaust the memory
+	message := limitedWriter{limit: maxTemplateLength * 2}
+	err = parsedTemplate.Execute(&message, data.values())
+	if err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(message.String())) == 0 {
+		return "", i18n.NewError("validation.template_empty")
+	}
+	return message.String(), nil
+}
+
+// limitedWriter buffer that fails once more than limit bytes are written to it
+type limitedWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write appends to the buffer, or fails without writing if the buffer would exceed its limit
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, i18n.NewError("validation.template_too_long", "max", strconv.Itoa(w.limit))
+	}
+	return w.Buffer.Write(p)
+}
+
+// values returns the template variables, names are lowercase to match the rest of the bot commands
+func (d Data) values() map[string]interface{} {
+	return map[string]interface{}{
+		"word":   d.Word,
+		"karma":  d.Karma,
+		"delta":  d.Delta,
+		"giver":  d.Giver,
+		"global": d.Global,
+		"reason": d.Reason,
+	}
+}
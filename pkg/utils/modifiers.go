@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+)
+
+// GetKarmaModifiers returns the modifiers available on a channel and the karma they give,
+// custom modifiers override the default ones
+func GetKarmaModifiers(db database.Database, channelName string) map[string]int {
+	modifiers := map[string]int{}
+	for modifier, karma := range parser.DefaultModifiers {
+		modifiers[modifier] = karma
+	}
+	for modifier, karma := range db.GetModifiers(channelName) {
+		modifiers[modifier] = karma
+	}
+	return modifiers
+}
+
+// CapKarma bounds the karma given at once to the maximum configured
+func CapKarma(karma int, maxKarma int) int {
+	if karma > maxKarma {
+		return maxKarma
+	}
+	if karma < -maxKarma {
+		return -maxKarma
+	}
+	return karma
+}
@@ -8,63 +8,35 @@ import (
 	"time"
 
 	"github.com/mvazquezc/karma-bot/pkg/database"
+	"github.com/mvazquezc/karma-bot/pkg/i18n"
+	"github.com/mvazquezc/karma-bot/pkg/parser"
+	"github.com/mvazquezc/karma-bot/pkg/settings"
+	"github.com/mvazquezc/karma-bot/pkg/slackcache"
+	"github.com/mvazquezc/karma-bot/pkg/templates"
 	"github.com/slack-go/slack"
 )
 
-// FixEmptyKarma When user types @user and hits tab a space is inserted
-// that ends up in a space between the user handler and the karma modifier
-// this function will fix that by removing that space when detected
-func FixEmptyKarma(text []string) []string {
-	karmaModifiers := map[string]bool{
-		"++":  true,
-		"--":  true,
-		"+++": true,
-		"---": true,
-	}
-	var finalText []string
-	var finalIndex int = 0
-	for index, word := range text {
-		var newWord string = word
-
-		if karmaModifiers[word] && index > 0 && !strings.HasSuffix(finalText[finalIndex-1], word) {
-			newWord = text[index-1] + newWord
-			// We only want to fix the extra space added when pressing tab for autocomplete a user handler
-			r := regexp.MustCompile("(<@)(.*)(>)")
-			matched := r.MatchString(newWord)
-			if matched {
-				// The word preceding the karma modifiers and a space is a username, we want to fix it
-				finalText[finalIndex-1] = newWord
-			}
-		} else {
-			finalIndex++
-			finalText = append(finalText, newWord)
-		}
-	}
-	return finalText
-}
-
 // HandleKarma Updates the karma for a given word and sends a message if required
-func HandleKarma(rtm *slack.RTM, ev *slack.MessageEvent, db database.Database, word string, channelName string, karmaCounter int) {
+// It returns true when the giver has no karma budget left, the giver is only told about it when notifyBudget is true
+// so a message giving karma to many words sends a single notice
+func HandleKarma(rtm *slack.RTM, api *slackcache.Client, ev *slack.MessageEvent, db database.Database, word string, channelName string, karmaCounter int, reason string, notifyBudget bool) (budgetExhausted bool) {
 
 	// Sanitize word in case it has ' to avoid SQL errors
 	word = strings.ReplaceAll(word, "'", "")
 
-	alias := db.GetAlias(word, channelName)
+	// Aliases are stored as typed, their karma is kept under the normalized spelling
+	alias := parser.Normalize(db.GetAlias(word, channelName))
 
-	useKarmaEmojisSetting := db.GetSetting(channelName, "use_karma_emojis")
-
-	if len(useKarmaEmojisSetting) <= 0 {
-		useKarmaEmojisSetting = "0"
-	}
-	useKarmaEmojis, _ := strconv.Atoi(useKarmaEmojisSetting)
+	useKarmaEmojis := db.GetBoolSetting(channelName, "use_karma_emojis")
+	language := db.GetSettingValue(channelName, "language")
 
 	user := strings.ToLower("<@" + ev.User + ">")
-	userAlias := db.GetAlias(user, channelName)
+	userAlias := parser.Normalize(db.GetAlias(user, channelName))
 
 	if word == userAlias {
 		// Check that user is not giving karma to one of their aliases
 		log.Printf("User %s granted karma to theirself, skipping", user)
-		return
+		return false
 	}
 
 	if len(alias) > 0 {
@@ -72,33 +44,55 @@ func HandleKarma(rtm *slack.RTM, ev *slack.MessageEvent, db database.Database, w
 		word = alias
 	}
 
-	//Check karma cooldown (10s)
+	//Check karma cooldown (karma_cooldown setting)
 	if !db.KarmaCooldownTimeout(channelName, word, ev.User) {
 		log.Printf("User %s has an active cooldown for word %s in channel %s", ev.User, word, channelName)
-		return
+		return false
+	}
+
+	// Check the giver still has karma budget left for the current period
+	budget := db.GetKarmaBudget(channelName, strings.ToLower(ev.User))
+	if !budget.Allows(karmaCounter) {
+		log.Printf("User %s has no karma budget left for word %s in channel %s", ev.User, word, channelName)
+		if notifyBudget {
+			budgetMessage := i18n.T(language, "budget.exhausted", "word", DisplayWord(api, word), "budget", FormatKarmaBudget(budget, language))
+			_, err := rtm.PostEphemeral(ev.Channel, ev.User, slack.MsgOptionText(budgetMessage, false))
+			if err != nil {
+				log.Printf("Error sending budget notice to user %s: %s", ev.User, err)
+			}
+		}
+		return true
 	}
 
 	if karmaCounter != 0 {
-		wordKarma, notifyKarma, intWordKarma := db.UpdateKarma(channelName, word, karmaCounter, ev.User, time.Now().Unix())
+		karmaTimestamp := time.Now().Unix()
+		_, notifyKarma, _ := db.UpdateKarma(channelName, word, karmaCounter, ev.User, karmaTimestamp)
+		db.LogKarma(channelName, word, strings.ToLower(ev.User), karmaCounter, karmaTimestamp)
+		// Displayed karma has the channel decay policy applied
+		intWordKarma := db.GetDisplayKarma(channelName, word)
 		// Only send emojis if those are enabled in the channel
 		karmaEmoji := ""
-		globalKarmaMsg := ""
-		if useKarmaEmojis == 1 {
-			karmaEmoji = ":thumbsup:"
+		if useKarmaEmojis {
+			karmaEmojis := db.GetListSetting(channelName, "positive_karma_emojis")
 			if karmaCounter < 0 {
-				karmaEmoji = ":thumbsdown:"
+				karmaEmojis = db.GetListSetting(channelName, "negative_karma_emojis")
+			}
+			if len(karmaEmojis) > 0 {
+				// Rotate emojis based on the karma so consecutive notifications use different emojis
+				emojiIndex := intWordKarma % len(karmaEmojis)
+				if emojiIndex < 0 {
+					emojiIndex = -emojiIndex
+				}
+				karmaEmoji = karmaEmojis[emojiIndex]
 			}
 		}
 		if notifyKarma {
 			// Get Global Karma
 			globalKarma := db.GetGlobalKarma(word)
 			log.Printf("Word karma %d, global karma %d", intWordKarma, globalKarma)
-			// We only want to add the global karma if the word has karma outside this channel
-			if (globalKarma > intWordKarma || globalKarma < intWordKarma) && globalKarma != 0 {
-				globalKarmaStr := strconv.Itoa(globalKarma)
-				globalKarmaMsg = "(`" + globalKarmaStr + "` points across channels) "
-			}
-			karmaMessage := "`" + word + "` has `" + wordKarma + "` karma points! " + globalKarmaMsg + karmaEmoji
+			// The default template only adds the global karma if the word has karma outside this channel
+			templateData := templates.Data{Word: DisplayWord(api, word), Karma: intWordKarma, Delta: karmaCounter, Giver: "<@" + ev.User + ">", Global: globalKarma, Reason: reason}
+			karmaMessage := RenderMessage(db, channelName, "karma_notification", templateData) + karmaEmoji
 			resp := rtm.NewOutgoingMessage(karmaMessage, ev.Channel)
 			// Check if message is from a thread, and if so set the response to be in-thread
 			if ev.Msg.ThreadTimestamp != "" {
@@ -106,41 +100,158 @@ func HandleKarma(rtm *slack.RTM, ev *slack.MessageEvent, db database.Database, w
 			} else { // Reply in a new thread otherwise
 				resp.ThreadTimestamp = ev.Msg.Timestamp
 			}
+			if db.GetBoolSetting(channelName, "use_block_kit") {
+				// The RTM API cannot send blocks, the plain text message is used as fallback
+				blocks := karmaBlocks(language, karmaMessage, karmaCounter, ev.User)
+				_, _, err := rtm.PostMessage(ev.Channel, slack.MsgOptionText(karmaMessage, false), slack.MsgOptionBlocks(blocks...), slack.MsgOptionTS(resp.ThreadTimestamp))
+				if err == nil {
+					return false
+				}
+				log.Printf("Cannot send karma message with blocks, sending plain text instead: %s", err)
+			}
 			rtm.SendMessage(resp)
 		}
 	}
+	return false
+}
+
+// karmaBlocks returns the Block Kit rendering of a karma notification
+func karmaBlocks(language string, karmaMessage string, karmaCounter int, giver string) []slack.Block {
+	delta := strconv.Itoa(karmaCounter)
+	if karmaCounter > 0 {
+		delta = "+" + delta
+	}
+	context := i18n.T(language, "karma.notification_context", "delta", delta, "giver", "<@"+giver+">")
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, karmaMessage, false, false), nil, nil),
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, context, false, false)),
+	}
+}
+
+// RenderMessage renders a message template, using the template configured for the channel if there is one
+// Channel templates are validated when set, but if one fails to render the default template is used instead
+func RenderMessage(db database.Database, channelName string, name string, data templates.Data) string {
+	channelTemplate := db.GetTemplate(channelName, name)
+	if len(channelTemplate) > 0 {
+		message, err := templates.Render(channelTemplate, data)
+		if err == nil {
+			return message
+		}
+		log.Printf("Cannot render template %s for channel %s, using the default template: %s", name, channelName, err)
+	}
+	message, err := templates.Render(templates.Default(db.GetSettingValue(channelName, "language"), name), data)
+	if err != nil {
+		// Default templates are part of the message catalog, a broken translation should not take the bot down
+		log.Printf("Cannot render the default template %s for channel %s, using a plain message: %s", name, channelName, err)
+		return plainMessage(data)
+	}
+	return message
+}
+
+// plainMessage returns a message with the template data that does not depend on any template
+func plainMessage(data templates.Data) string {
+	message := data.Word + ": " + strconv.Itoa(data.Karma)
+	if data.Delta != 0 {
+		message += " (" + strconv.Itoa(data.Delta) + ")"
+	}
+	return message
+}
+
+// userWordRegex matches the words karma is stored under for users, their mention with the user ID
+var userWordRegex = regexp.MustCompile("^<@([a-z0-9]+)>$")
+
+// UserDisplayName returns the name a user is shown with: the display name, or the real name if it is not set,
+// normalized and with spaces replaced by dots
+func UserDisplayName(user *slack.User) string {
+	displayName := user.Profile.DisplayNameNormalized
+	if len(displayName) <= 0 {
+		displayName = user.Profile.RealName
+	}
+	return strings.Replace(parser.Normalize(displayName), " ", ".", -1)
 }
 
-// GetUsername Queries the Slack API in order to get the configured name for a given user
-func GetUsername(api *slack.Client, word string) string {
-	log.Printf("Getting username for user %s", word)
-	displayName := "not_set"
-	r := regexp.MustCompile("(<@)(.*)(>)")
-	captureGroups := r.FindStringSubmatch(word)
-	userName := captureGroups[2]
-	userName = strings.ToUpper(userName)
-	user, err := api.GetUserInfo(userName)
+// DisplayWord returns how a karma word is shown in messages. Users are stored by their ID, which never changes,
+// and are shown with their current name
+func DisplayWord(api *slackcache.Client, word string) string {
+	captureGroups := userWordRegex.FindStringSubmatch(word)
+	if captureGroups == nil || api == nil {
+		return word
+	}
+	userID := strings.ToUpper(captureGroups[1])
+	user, err := api.GetUserInfo(userID)
 	if err != nil {
-		panic(err)
+		log.Printf("Cannot get the name of user %s: %s", userID, err)
+		return "@" + userID
+	}
+	return UserDisplayName(user)
+}
+
+// GetUserKarmaWord returns the word that accumulates karma for a user mention in a channel,
+// that is the alias configured for the mention or the mention itself, so karma is kept by user ID
+func GetUserKarmaWord(db database.Database, mention string, channelName string) string {
+	alias := db.GetAlias(mention, channelName)
+	if len(alias) > 0 {
+		log.Printf("User %s has an alias configured, using alias %s", mention, alias)
+		// Aliases are stored as typed, their karma is kept under the normalized spelling like any other word
+		return parser.Normalize(alias)
 	}
-	if len(user.Profile.DisplayNameNormalized) > 0 {
-		displayName = strings.ToLower(user.Profile.DisplayNameNormalized)
-	} else {
-		displayName = strings.ToLower(user.Profile.RealName)
+	return mention
+}
+
+// FormatKarmaBudget returns a human readable description of the karma budget usage
+func FormatKarmaBudget(budget database.KarmaBudget, language string) string {
+	period := i18n.T(language, "budget.period.today")
+	if budget.Days > 1 {
+		period = i18n.T(language, "budget.period.days", "days", strconv.Itoa(budget.Days))
+	}
+	formatLimit := func(used int, limit int) string {
+		if limit <= 0 {
+			return i18n.T(language, "budget.unlimited", "used", strconv.Itoa(used))
+		}
+		return "`" + strconv.Itoa(used) + "/" + strconv.Itoa(limit) + "`"
 	}
-	log.Printf("Display name for user %s is %s", userName, displayName)
-	return strings.Replace(displayName, " ", ".", -1)
+	return i18n.T(language, "budget.usage", "period", period, "positive", formatLimit(budget.PositiveUsed, budget.PositiveLimit), "negative", formatLimit(budget.NegativeUsed, budget.NegativeLimit))
+}
+
+// GetCommandText returns the command part of a message and true if the message invokes the bot, that is
+// when it starts with the command prefix or a mention to the bot, or when it is sent to the bot in a DM.
+// The prefix and the mention are case insensitive, the command is returned as typed
+func GetCommandText(text string, commandPrefix string, botMention string, isDM bool) (string, bool) {
+	switch {
+	case hasPrefixFold(text, commandPrefix+" "):
+		return strings.TrimSpace(text[len(commandPrefix)+1:]), true
+	case hasPrefixFold(text, botMention):
+		// Mentions are usually followed by a colon when autocompleted, e.g. "@karmabot: rank karma"
+		commandText := text[len(botMention):]
+		commandText = strings.TrimPrefix(commandText, ":")
+		return strings.TrimSpace(commandText), true
+	case isDM:
+		return text, true
+	}
+	return "", false
+}
+
+// hasPrefixFold returns true if the text starts with the prefix, ignoring case
+func hasPrefixFold(text string, prefix string) bool {
+	return len(text) >= len(prefix) && strings.EqualFold(text[:len(prefix)], prefix)
 }
 
-// PrintCommandsUsage Prints a help messages for implemented commands
-func PrintCommandsUsage(rtm *slack.RTM, ev *slack.MessageEvent) {
-	karmaHelp := "*Karma Commands*:\n- Add/Remove karma to the word's current karma: `kb set karma <word> <+karma|-karma>`\n- Reset karma for a given word: `kb del karma <word>`\n- Get current karma for a given word: `kb get karma <word>`\n- Get current karma ranking for the channel: `kb rank karma [all]`\n"
-	adminHelp := "*Admin Commands*:\n- Set admin on current channel: `kb set admin @user`\n- Get admins on current channel: `kb get admin`\n- Remove admin on current channel: `kb del admin @user`\n"
-	settingsHelp := "*Settings Commands*:\n- Set setting on current channel: `kb set setting <setting_name> <setting_value>`\n- Get setting value on current channel: `kb get setting <setting_name>`\n"
-	aliasHelp := "*Alias Commands*:\n- Set alias for a given word on current channel: `kb set alias <word> <alias>`\n- Get aliases for a word on current channel: `kb get alias <word>`\n- Remove alias for a word: `kb del alias <word> <alias>`\n"
-	rankHelp := "*Rank Commands*:\n- Get top 10 words on current channel: `kb rank karma`\n- Get full rank of words on current channel: `kb rank karma all`\n- Get top 10 words rank of words across channels: `kb rank globalkarma`\n- Get full rank of words across channels: `kb rank globalkarma all`"
-	commandsHelp := karmaHelp + adminHelp + settingsHelp + aliasHelp + rankHelp
-	rtm.SendMessage(rtm.NewOutgoingMessage(commandsHelp, ev.Channel))
+// GetCommandsUsage Returns the help message for implemented commands in the given language using the given command prefix
+func GetCommandsUsage(commandPrefix string, language string) string {
+	settingsHelp := i18n.T(language, "help.settings")
+	for _, setting := range settings.All() {
+		settingsHelp += i18n.T(language, "help.setting_entry", "setting", setting.Name, "usage", setting.Usage(language), "default", setting.Default, "help", i18n.T(language, "setting."+setting.Name))
+	}
+	templatesHelp := i18n.T(language, "help.templates")
+	for _, name := range templates.Names() {
+		templatesHelp += i18n.T(language, "help.template_entry", "template", name, "help", templates.Help(language, name))
+	}
+	commandsHelp := i18n.T(language, "help.karma") + i18n.T(language, "help.admin") + settingsHelp + templatesHelp + i18n.T(language, "help.modifiers") + i18n.T(language, "help.alias") + i18n.T(language, "help.rank") + "\n" + i18n.T(language, "help.invocation", "prefix", commandPrefix)
+	if commandPrefix != "kb" {
+		commandsHelp = strings.ReplaceAll(commandsHelp, "`kb ", "`"+commandPrefix+" ")
+	}
+	// Template help messages mention commands with the {prefix} placeholder
+	return strings.ReplaceAll(commandsHelp, "{prefix}", commandPrefix)
 }
 
 // Contains returns true if a string is found on a slice
@@ -152,3 +263,69 @@ func Contains(list []string, s string) bool {
 	}
 	return false
 }
+
+// GetUserGroupKarmaWord returns the word that accumulates karma for a user group, its handle like @sre
+func GetUserGroupKarmaWord(api *slackcache.Client, groupID string) string {
+	userGroups, err := api.GetUserGroups(slack.GetUserGroupsOptionIncludeDisabled(true))
+	if err != nil {
+		log.Printf("Cannot get user groups: %s", err)
+		return ""
+	}
+	for _, userGroup := range userGroups {
+		if userGroup.ID == groupID {
+			return "@" + parser.Normalize(userGroup.Handle)
+		}
+	}
+	log.Printf("User group %s not found", groupID)
+	return ""
+}
+
+// GetUserGroupMembers returns the IDs of the users in a user group
+func GetUserGroupMembers(api *slackcache.Client, groupID string) []string {
+	members, err := api.GetUserGroupMembers(groupID)
+	if err != nil {
+		log.Printf("Cannot get members for user group %s: %s", groupID, err)
+		return nil
+	}
+	return members
+}
+
+// GetChannelKarmaWord returns the word that accumulates karma for a channel, its name like #general
+func GetChannelKarmaWord(api *slackcache.Client, channelID string) string {
+	channel, err := api.GetConversationInfo(channelID, false)
+	if err != nil {
+		log.Printf("Cannot get information for channel %s: %s", channelID, err)
+		return ""
+	}
+	return "#" + parser.Normalize(channel.NameNormalized)
+}
+
+// GetKarmaRecipients returns the members that receive karma given to @here, @channel, @everyone or a user group:
+// bots, deactivated users and the giver are left out, and @here only includes active users.
+// tooMany is true when there are more than maxRecipients recipients, the list is not complete then
+func GetKarmaRecipients(api *slackcache.Client, members []string, broadcast string, giver string, maxRecipients int) (recipients []string, tooMany bool) {
+	for _, member := range members {
+		if member == giver || member == "USLACKBOT" {
+			continue
+		}
+		user, err := api.GetUserInfo(member)
+		if err != nil {
+			log.Printf("Cannot get information for user %s, skipping: %s", member, err)
+			continue
+		}
+		if user.IsBot || user.Deleted {
+			continue
+		}
+		if broadcast == "here" {
+			presence, err := api.GetUserPresence(member)
+			if err != nil || presence.Presence != "active" {
+				continue
+			}
+		}
+		if len(recipients) >= maxRecipients {
+			return recipients, true
+		}
+		recipients = append(recipients, member)
+	}
+	return recipients, false
+}